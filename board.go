@@ -1,13 +1,353 @@
 package main
 
 import (
+	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/vakrim/carcassonne-wave-collapse/tile"
 )
 
+// Rect is a rectangular area of cells
+type Rect struct {
+	Row, Col      int // top left cell
+	Height, Width int
+}
+
+func (r Rect) Contains(row, col int) bool {
+	return row >= r.Row && row < r.Row+r.Height && col >= r.Col && col < r.Col+r.Width
+}
+
+// Grow returns the area extended by the given number of cells on every side
+func (r Rect) Grow(cells int) Rect {
+	return Rect{Row: r.Row - cells, Col: r.Col - cells, Height: r.Height + 2*cells, Width: r.Width + 2*cells}
+}
+
+// Board holds the placed tiles by position. A bounded board only has the
+// cells of a fixed rectangle, an unbounded board grows in every direction,
+// including negative rows and columns. Blocked cells are not part of the
+// board at all, so boards can have any shape and holes. Pinned tiles were
+// placed before solving and may not be removed.
 type Board struct {
-	tiles [][]*tile.Tile
+	tiles     map[cellPosition]*tile.Tile
+	blocked   map[cellPosition]bool
+	pinned    map[cellPosition]bool
+	bounds    Rect  // cells of a bounded board
+	edges     Edges // rules for the edges of a bounded board
+	topology  Topology
+	unbounded bool
+
+	// features tracks the features across the tiles once asked for, see
+	// Place
+	features *featureTracker
+
+	// trail holds the undo steps of every change once a mark has been
+	// taken, see Mark and Undo
+	trail []func()
+}
+
+func NewBoard(width, height int) Board {
+	return Board{
+		tiles:    make(map[cellPosition]*tile.Tile),
+		blocked:  make(map[cellPosition]bool),
+		pinned:   make(map[cellPosition]bool),
+		bounds:   Rect{Height: height, Width: width},
+		topology: rectTopology{bounds: Rect{Height: height, Width: width}},
+	}
+}
+
+// NewTorusBoard creates a bounded board whose opposite edges are joined, so
+// the result can be repeated seamlessly in every direction
+func NewTorusBoard(width, height int) Board {
+	board := NewBoard(width, height)
+	wrap := EdgeRule{Kind: EdgeWrap}
+	if err := board.SetEdges(Edges{wrap, wrap, wrap, wrap}); err != nil {
+		panic(err)
+	}
+	return board
+}
+
+// NewUnboundedBoard creates an empty board without edges
+func NewUnboundedBoard() Board {
+	return Board{
+		tiles:     make(map[cellPosition]*tile.Tile),
+		blocked:   make(map[cellPosition]bool),
+		pinned:    make(map[cellPosition]bool),
+		topology:  planeTopology{},
+		unbounded: true,
+	}
+}
+
+// NewHexBoard creates a bounded board of six-sided cells in axial
+// coordinates, see hexTopology
+func NewHexBoard(width, height int) Board {
+	board := NewBoard(width, height)
+	board.topology = hexTopology{bounds: board.bounds}
+	return board
+}
+
+// NewUnboundedHexBoard creates an empty board of six-sided cells without
+// edges
+func NewUnboundedHexBoard() Board {
+	board := NewUnboundedBoard()
+	board.topology = hexTopology{unbounded: true}
+	return board
+}
+
+func (b *Board) Bounded() bool {
+	return !b.unbounded
+}
+
+// Contains reports whether a tile may be placed at the given position
+func (b *Board) Contains(row, col int) bool {
+	if b.blocked[cellPosition{row, col}] {
+		return false
+	}
+	return b.unbounded || b.bounds.Contains(row, col)
+}
+
+// Block takes an empty cell out of the board, e.g. to leave a hole in it
+func (b *Board) Block(row, col int) {
+	if b.Get(row, col) != nil {
+		panic(fmt.Sprintf("Cell [%d][%d] is not empty", row, col))
+	}
+	pos := cellPosition{row, col}
+	if b.trail != nil && !b.blocked[pos] {
+		b.trail = append(b.trail, func() { delete(b.blocked, pos) })
+	}
+	b.blocked[pos] = true
+}
+
+func (b *Board) Blocked(row, col int) bool {
+	return b.blocked[cellPosition{row, col}]
+}
+
+// CellCount returns the number of cells of a bounded board that tiles may be
+// placed on
+func (b *Board) CellCount() int {
+	if b.unbounded {
+		panic("An unbounded board has no cell count")
+	}
+	count := b.bounds.Height * b.bounds.Width
+	for pos := range b.blocked {
+		if b.bounds.Contains(pos.row, pos.col) {
+			count--
+		}
+	}
+	return count
+}
+
+// Bounds returns the whole board when it is bounded, or the bounding box of
+// the placed tiles otherwise
+func (b *Board) Bounds() Rect {
+	if !b.unbounded {
+		return b.bounds
+	}
+	if len(b.tiles) == 0 {
+		return Rect{}
+	}
+
+	first := true
+	var minRow, maxRow, minCol, maxCol int
+	for pos := range b.tiles {
+		if first {
+			minRow, maxRow, minCol, maxCol = pos.row, pos.row, pos.col, pos.col
+			first = false
+			continue
+		}
+		minRow, maxRow = min(minRow, pos.row), max(maxRow, pos.row)
+		minCol, maxCol = min(minCol, pos.col), max(maxCol, pos.col)
+	}
+	return Rect{Row: minRow, Col: minCol, Height: maxRow - minRow + 1, Width: maxCol - minCol + 1}
+}
+
+// Area returns the cells where tiles are or may go next: the whole board when
+// it is bounded, or the bounding box of the placed tiles with a margin of one
+// cell otherwise
+func (b *Board) Area() Rect {
+	if !b.unbounded {
+		return b.bounds
+	}
+	if len(b.tiles) == 0 {
+		return Rect{Height: 1, Width: 1}
+	}
+	return b.Bounds().Grow(1)
+}
+
+func (b *Board) Width() int {
+	return b.Bounds().Width
+}
+
+func (b *Board) Height() int {
+	return b.Bounds().Height
+}
+
+// Sides returns the number of sides of the cells, and of the tiles that go
+// in them
+func (b *Board) Sides() int {
+	return b.topology.Sides()
+}
+
+// SetEdges changes the rules for the edges of a bounded square board
+func (b *Board) SetEdges(edges Edges) error {
+	if b.unbounded && edges != (Edges{}) {
+		return fmt.Errorf("an unbounded board has no edges")
+	}
+	if b.Sides() != int(tile.SideCount) && edges != (Edges{}) {
+		return fmt.Errorf("edge rules only apply to square boards")
+	}
+	if err := edges.validate(); err != nil {
+		return err
+	}
+	b.edges = edges
+	if !b.unbounded && b.Sides() == int(tile.SideCount) {
+		b.topology = rectTopology{
+			bounds:   b.bounds,
+			wrapRows: edges[tile.SideTop].Kind == EdgeWrap,
+			wrapCols: edges[tile.SideLeft].Kind == EdgeWrap,
+		}
+	}
+	return nil
+}
+
+func (b *Board) Edges() Edges {
+	return b.edges
+}
+
+func (b *Board) Topology() Topology {
+	return b.topology
+}
+
+// Wraps reports whether any edge of the board is joined to the opposite one
+func (b *Board) Wraps() bool {
+	return b.edges[tile.SideTop].Kind == EdgeWrap || b.edges[tile.SideLeft].Kind == EdgeWrap
+}
+
+// Neighbour returns the cell across the given side of a cell, as decided by
+// the topology of the board. Past a wrapping edge this is the cell on the
+// other side of the board, past any other edge there is no neighbour.
+func (b *Board) Neighbour(row, col int, side tile.Side) (int, int, bool) {
+	return b.topology.Neighbour(row, col, side)
+}
+
+// Get returns the tile at the given position, or nil if the cell is empty or
+// outside the board
+func (b *Board) Get(row, col int) *tile.Tile {
+	return b.tiles[cellPosition{row, col}]
+}
+
+// Set puts a tile on the board, or empties the cell when t is nil
+func (b *Board) Set(row, col int, t *tile.Tile) {
+	if !b.Contains(row, col) {
+		panic(fmt.Sprintf("Cell [%d][%d] is not on the board", row, col))
+	}
+	if t != nil && t.Sides() != b.Sides() {
+		panic(fmt.Sprintf("Tile %s does not fit the %d-sided cells of the board", t.String(), b.Sides()))
+	}
+	if b.pinned[cellPosition{row, col}] {
+		panic(fmt.Sprintf("Cell [%d][%d] holds a pinned tile", row, col))
+	}
+	pos := cellPosition{row, col}
+	if b.trail != nil {
+		previous := b.tiles[pos]
+		b.trail = append(b.trail, func() { b.put(pos, previous) })
+	}
+	b.put(pos, t)
+}
+
+// put changes a cell and its features without any checks
+func (b *Board) put(pos cellPosition, t *tile.Tile) {
+	if b.features != nil && b.tiles[pos] != nil {
+		b.untrackTile(pos)
+	}
+	if t == nil {
+		delete(b.tiles, pos)
+		return
+	}
+	b.tiles[pos] = t
+	if b.features != nil {
+		b.features.place(b, pos)
+	}
+}
+
+// Pin puts a tile on the board for good: it can never be replaced or removed,
+// other than by Undo
+func (b *Board) Pin(row, col int, t *tile.Tile) {
+	b.Set(row, col, t)
+	pos := cellPosition{row, col}
+	b.pinned[pos] = true
+	if b.trail != nil {
+		// Unpin before the tile is taken away by the step of Set
+		b.trail = append(b.trail, func() { delete(b.pinned, pos) })
+	}
+}
+
+// Mark returns the current position in the undo trail. The board records
+// its changes from the first mark on, so that boards that are never marked
+// pay nothing for it.
+func (b *Board) Mark() int {
+	if b.trail == nil {
+		b.trail = []func(){}
+	}
+	return len(b.trail)
+}
+
+// Undo reverts every change made since the given mark: placed, replaced and
+// removed tiles, pinned tiles and blocked cells
+func (b *Board) Undo(mark int) {
+	for i := len(b.trail) - 1; i >= mark; i-- {
+		b.trail[i]()
+	}
+	b.trail = b.trail[:mark]
+}
+
+func (b *Board) Pinned(row, col int) bool {
+	return b.pinned[cellPosition{row, col}]
+}
+
+// PinnedTiles returns the pinned tiles in row-major order
+func (b *Board) PinnedTiles() []tile.Tile {
+	positions := make([]cellPosition, 0, len(b.pinned))
+	for pos := range b.pinned {
+		positions = append(positions, pos)
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i].row != positions[j].row {
+			return positions[i].row < positions[j].row
+		}
+		return positions[i].col < positions[j].col
+	})
+
+	tiles := make([]tile.Tile, len(positions))
+	for i, pos := range positions {
+		tiles[i] = *b.tiles[pos]
+	}
+	return tiles
+}
+
+// Clone returns a copy of the board with its own cells and features, which
+// can be changed without changing this board. The tiles are shared as they
+// are never changed once placed. The undo trail is not copied, so the copy
+// cannot undo changes made before it was taken.
+func (b *Board) Clone() *Board {
+	clone := *b
+	clone.tiles = make(map[cellPosition]*tile.Tile, len(b.tiles))
+	for pos, t := range b.tiles {
+		clone.tiles[pos] = t
+	}
+	clone.blocked = make(map[cellPosition]bool, len(b.blocked))
+	for pos := range b.blocked {
+		clone.blocked[pos] = true
+	}
+	clone.pinned = make(map[cellPosition]bool, len(b.pinned))
+	for pos := range b.pinned {
+		clone.pinned[pos] = true
+	}
+	if b.features != nil {
+		clone.features = b.features.clone()
+	}
+	clone.trail = nil
+	return &clone
 }
 
 type PossibilitiesCount struct {
@@ -15,21 +355,35 @@ type PossibilitiesCount struct {
 	alreadyPlaced bool
 }
 
-func (b *Board) CountPossibilities(pile *Pile) [][]PossibilitiesCount {
-	possibilities := make([][]PossibilitiesCount, len(b.tiles))
-	for i := range b.tiles {
-		possibilities[i] = make([]PossibilitiesCount, len(b.tiles[i]))
-		for j := range b.tiles[i] {
-			if b.tiles[i][j] == nil {
-				possibilities[i][j] = PossibilitiesCount{
-					possibilities: pile.CountMatchingTiles(b.GetTilePattern(i, j)),
-					alreadyPlaced: false,
-				}
-			} else {
+// TileCounter counts the tiles that fit a pattern in any rotation, such as a
+// Pile or a CountedPile
+type TileCounter interface {
+	CountMatching(pattern tile.Pattern) int
+}
+
+// CountPossibilities counts the tiles that fit every cell of Bounds
+func (b *Board) CountPossibilities(pile TileCounter) [][]PossibilitiesCount {
+	return b.CountPossibilitiesIn(b.Bounds(), pile)
+}
+
+// CountPossibilitiesIn counts the tiles that fit every cell of an area. The
+// first row of the result is area.Row, the first column area.Col.
+func (b *Board) CountPossibilitiesIn(area Rect, pile TileCounter) [][]PossibilitiesCount {
+	possibilities := make([][]PossibilitiesCount, area.Height)
+	for i := range possibilities {
+		possibilities[i] = make([]PossibilitiesCount, area.Width)
+		for j := range possibilities[i] {
+			row, col := area.Row+i, area.Col+j
+			if b.Get(row, col) != nil {
 				possibilities[i][j] = PossibilitiesCount{
 					possibilities: 0,
 					alreadyPlaced: true,
 				}
+			} else if b.Contains(row, col) {
+				possibilities[i][j] = PossibilitiesCount{
+					possibilities: pile.CountMatching(b.PatternAt(row, col)),
+					alreadyPlaced: false,
+				}
 			}
 		}
 	}
@@ -37,65 +391,166 @@ func (b *Board) CountPossibilities(pile *Pile) [][]PossibilitiesCount {
 }
 
 func (b *Board) GetTilePattern(row, col int) string {
-	pattern := ""
-	if row > 0 && b.tiles[row-1][col] != nil {
-		pattern += b.tiles[row-1][col].Bottom()
-	} else {
-		pattern += "?"
-	}
-	if col < len(b.tiles[row])-1 && b.tiles[row][col+1] != nil {
-		pattern += b.tiles[row][col+1].Left()
-	} else {
-		pattern += "?"
-	}
-	if row < len(b.tiles)-1 && b.tiles[row+1][col] != nil {
-		pattern += b.tiles[row+1][col].Top()
-	} else {
-		pattern += "?"
-	}
-	if col > 0 && b.tiles[row][col-1] != nil {
-		pattern += b.tiles[row][col-1].Right()
-	} else {
-		pattern += "?"
+	return b.PatternAt(row, col).Format(b.Sides())
+}
+
+// PatternAt returns the packed pattern a tile must match to be placed at the
+// given position. Sides facing an edge that requires a border must show it,
+// other sides without a neighbouring tile, including sides next to blocked
+// cells, are wildcards.
+func (b *Board) PatternAt(row, col int) tile.Pattern {
+	var pattern tile.Pattern
+	for side := range tile.Side(b.Sides()) {
+		neighbourRow, neighbourCol, ok := b.Neighbour(row, col, side)
+		if !ok {
+			// Edge rules are only ever set on square boards
+			if side < tile.SideCount && b.edges[side].Kind == EdgeBorder {
+				pattern = pattern.With(side, b.edges[side].Border)
+			}
+			continue
+		}
+		if neighbour := b.Get(neighbourRow, neighbourCol); neighbour != nil {
+			// The neighbour sees the segments of the shared side the other
+			// way round
+			pattern = pattern.WithSegments(side, neighbour.Segments(side.OppositeIn(b.Sides())).Mirror())
+		}
 	}
 	return pattern
 }
 
+// String draws the cells of Bounds, one line per row. The rows of a hex
+// board are not shifted.
 func (b *Board) String() string {
+	bounds := b.Bounds()
+	empty := strings.Repeat(" ", b.Sides())
+	blocked := strings.Repeat(blockedCellMarker, b.Sides())
 	var sb strings.Builder
-	for rowNumber, row := range b.tiles {
-		for _, t := range row {
+	for row := bounds.Row; row < bounds.Row+bounds.Height; row++ {
+		for col := bounds.Col; col < bounds.Col+bounds.Width; col++ {
 			sb.WriteString("[")
-			if t != nil {
-				sb.WriteString(t.String())
+			if t := b.Get(row, col); t != nil {
+				sb.WriteString(t.Describe())
+			} else if b.Blocked(row, col) {
+				sb.WriteString(blocked)
 			} else {
-				sb.WriteString("    ")
+				sb.WriteString(empty)
 			}
 			sb.WriteString("]")
 		}
-		if rowNumber < len(b.tiles)-1 {
+		if row < bounds.Row+bounds.Height-1 {
 			sb.WriteString("\n")
 		}
 	}
 	return sb.String()
 }
 
-const tileStringRepresentationLength = 6
+// blockedCellMarker is drawn instead of every border of a blocked cell
+const blockedCellMarker = "#"
 
+// BoardFromString creates a bounded board with the size of the drawing
 func BoardFromString(s string) Board {
-	lines := strings.Split(s, "\n")
-	board := Board{
-		tiles: make([][]*tile.Tile, len(lines)),
+	board, err := ParseBoard(s, false)
+	if err != nil {
+		panic(err)
 	}
+	return board
+}
+
+// UnboundedBoardFromString creates an unbounded board with the tiles of the
+// drawing, the top left cell being row 0 and column 0
+func UnboundedBoardFromString(s string) Board {
+	board, err := ParseBoard(s, true)
+	if err != nil {
+		panic(err)
+	}
+	return board
+}
+
+// ParseBoard reads a drawing in the format of Board.String. The board is
+// bounded to the size of the drawing unless unbounded is set. Cells with six
+// borders make a hex board.
+func ParseBoard(s string, unbounded bool) (Board, error) {
+	return parseBoard(s, unbounded, false)
+}
+
+// ParsePinnedBoard reads a drawing like ParseBoard, pinning the drawn tiles
+// so that they can never be removed
+func ParsePinnedBoard(s string, unbounded bool) (Board, error) {
+	return parseBoard(s, unbounded, true)
+}
+
+func parseBoard(s string, unbounded, pinned bool) (Board, error) {
+	lines := strings.Split(s, "\n")
+	rows := make([][]string, len(lines))
+	width := 0
 	for i, line := range lines {
-		board.tiles[i] = make([]*tile.Tile, len(line)/tileStringRepresentationLength)
-		for j := 0; j < len(line); j += tileStringRepresentationLength {
-			char := line[j : j+tileStringRepresentationLength]
-			if char != "[    ]" {
-				t := tile.CreateTile(string(char[1:5]))
-				board.tiles[i][j/tileStringRepresentationLength] = &t
+		cells, err := splitCells(line)
+		if err != nil {
+			return Board{}, fmt.Errorf("row %d, %w", i, err)
+		}
+		rows[i] = cells
+		width = max(width, len(cells))
+	}
+
+	sides := int(tile.SideCount)
+	if len(rows[0]) > 0 {
+		first := rows[0][0]
+		if t, err := tile.ParseTile(first); err == nil {
+			sides = t.Sides()
+		} else if strings.Trim(first, " "+blockedCellMarker) == "" {
+			sides = len(first)
+		}
+	}
+
+	var board Board
+	switch {
+	case sides == int(tile.HexSideCount) && unbounded:
+		board = NewUnboundedHexBoard()
+	case sides == int(tile.HexSideCount):
+		board = NewHexBoard(width, len(lines))
+	case unbounded:
+		board = NewUnboundedBoard()
+	default:
+		board = NewBoard(width, len(lines))
+	}
+
+	for i, cells := range rows {
+		for col, cell := range cells {
+			switch {
+			case strings.Trim(cell, " ") == "":
+			case strings.Trim(cell, blockedCellMarker) == "":
+				board.Block(i, col)
+			default:
+				t, err := tile.ParseTile(cell)
+				if err != nil {
+					return Board{}, fmt.Errorf("row %d, column %d: %w", i, col, err)
+				}
+				if t.Sides() != sides {
+					return Board{}, fmt.Errorf("row %d, column %d: tile %s has %d sides, expected %d", i, col, t.String(), t.Sides(), sides)
+				}
+				if pinned {
+					board.Pin(i, col, &t)
+				} else {
+					board.Set(i, col, &t)
+				}
 			}
 		}
 	}
-	return board
+	return board, nil
+}
+
+// splitCells returns what is written between the brackets of every cell of a
+// row. Cells are as wide as their tile, as sides with segments take more
+// letters.
+func splitCells(line string) ([]string, error) {
+	var cells []string
+	for len(line) > 0 {
+		end := strings.IndexByte(line, ']')
+		if line[0] != '[' || end < 0 {
+			return nil, fmt.Errorf("column %d: expected a cell like [CFRF], got %q", len(cells), line)
+		}
+		cells = append(cells, line[1:end])
+		line = line[end+1:]
+	}
+	return cells, nil
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/vakrim/carcassonne-wave-collapse/tile"
@@ -32,8 +33,8 @@ func TestGetTilePattern(t *testing.T) {
 
 	result := [][]string{}
 
-	for i := 0; i < len(board.tiles); i++ {
-		for j := 0; j < len(board.tiles[i]); j++ {
+	for i := 0; i < board.Height(); i++ {
+		for j := 0; j < board.Width(); j++ {
 			pattern := board.GetTilePattern(i, j)
 			if len(result) <= i {
 				result = append(result, []string{})
@@ -80,7 +81,7 @@ func TestCountPossibilities(t *testing.T) {
 		possibilities := board.CountPossibilities(&pile)
 		expected := [][]PossibilitiesCount{
 			{{3, false}, {1, false}, {3, false}},
-			{{2, false}, {0, true}, {0, false}},
+			{{2, false}, {0, true}, {1, false}},
 			{{3, false}, {1, false}, {0, true}},
 		}
 
@@ -89,3 +90,242 @@ func TestCountPossibilities(t *testing.T) {
 		}
 	})
 }
+
+func TestUnboundedBoardGrowsInEveryDirection(t *testing.T) {
+	board := UnboundedBoardFromString(`[    ][RCCC]`)
+	if bounds := board.Bounds(); bounds != (Rect{Row: 0, Col: 1, Height: 1, Width: 1}) {
+		t.Errorf("Expected the bounds to hold only the placed tile, got %+v", bounds)
+	}
+
+	corner := tile.CreateTile("CCCC")
+	board.Set(-2, -1, &corner)
+
+	expected := `[CCCC][    ][    ]
+[    ][    ][    ]
+[    ][    ][RCCC]`
+	if board.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, board.String())
+	}
+
+	pile := Pile{tile.CreateTile("FFFF"), tile.CreateTile("CCFF")}
+	possibilities := board.CountPossibilities(&pile)
+	if len(possibilities) != 3 || len(possibilities[0]) != 3 {
+		t.Fatalf("Expected possibilities for the 3x3 bounding box, got %v", possibilities)
+	}
+	if !possibilities[0][0].alreadyPlaced || possibilities[2][1] != (PossibilitiesCount{1, false}) {
+		t.Errorf("Expected counts relative to the top left corner, got %v", possibilities)
+	}
+
+	board.Set(-2, -1, nil)
+	if bounds := board.Bounds(); bounds != (Rect{Row: 0, Col: 1, Height: 1, Width: 1}) {
+		t.Errorf("Expected the bounds to shrink back, got %+v", bounds)
+	}
+	if area := board.Area(); area != (Rect{Row: -1, Col: 0, Height: 3, Width: 3}) {
+		t.Errorf("Expected the area to include a margin, got %+v", area)
+	}
+}
+
+func TestParsePinnedBoardPinsTiles(t *testing.T) {
+	if board := BoardFromString("[    ][CFFF]"); board.Pinned(0, 1) {
+		t.Errorf("Expected BoardFromString to leave the drawn tiles unpinned")
+	}
+
+	board, err := ParsePinnedBoard("[    ][CFFF]\n[RFRF][    ]", false)
+	if err != nil {
+		t.Fatalf("Expected the board to parse, got error: %v", err)
+	}
+	if !board.Pinned(0, 1) || !board.Pinned(1, 0) || board.Pinned(0, 0) {
+		t.Errorf("Expected exactly the drawn tiles to be pinned")
+	}
+	pinned := board.PinnedTiles()
+	if len(pinned) != 2 || pinned[0].String() != "CFFF" || pinned[1].String() != "RFRF" {
+		t.Errorf("Expected the pinned tiles in row-major order, got %v", pinned)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected removing a pinned tile to panic")
+		}
+	}()
+	board.Set(0, 1, nil)
+}
+
+func TestParseBoardKeepsTileFeatures(t *testing.T) {
+	drawing := "[CFCF T B][    ]\n[    ][C(FRF)(FRF)F T RcBc M]"
+	board, err := ParseBoard(drawing, false)
+	if err != nil {
+		t.Fatalf("Expected the board to parse, got error: %v", err)
+	}
+	// Fields left out of the drawing are written out
+	expected := "[CFCF T RL B][    ]\n[    ][C(FRF)(FRF)F T RlBrL RcBc RrBl M]"
+	if board.String() != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, board.String())
+	}
+	if !board.Get(1, 1).Monastery() || len(board.Get(0, 0).Features()) != 3 {
+		t.Errorf("Expected the features of the drawn tiles")
+	}
+}
+
+func TestLoadPileWithFeatures(t *testing.T) {
+	pile, err := loadTilesFromFile("features-tiles.txt")
+	if err != nil {
+		t.Fatalf("Expected features-tiles.txt to load, got error: %v", err)
+	}
+	if len(pile) != 10 || pile[5].TypeCode() == pile[6].TypeCode() {
+		t.Errorf("Expected 10 tiles with CFCF T B and CFCF TB told apart, got %v", pile)
+	}
+}
+
+func TestParseBoardErrors(t *testing.T) {
+	inputs := map[string]string{
+		"[CFFF][    ":    "row 0, column 1",
+		"[CFFF]\n(    )": "row 1, column 0",
+		"[CXFF]":         "row 0, column 0",
+	}
+	for input, expected := range inputs {
+		if _, err := ParseBoard(input, false); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected an error containing %q for %q, got %v", expected, input, err)
+		}
+	}
+}
+
+func TestPatternAtMatchesGetTilePattern(t *testing.T) {
+	board := BoardFromString(`[    ][    ][    ]
+[    ][RCCC][    ]
+[    ][    ][CCCC]`)
+
+	for row := range board.Height() {
+		for col := range board.Width() {
+			if packed, query := board.PatternAt(row, col), board.GetTilePattern(row, col); packed.String() != query {
+				t.Errorf("Expected packed pattern %s at [%d][%d], got %s", query, row, col, packed.String())
+			}
+		}
+	}
+}
+
+// The solver hot path checks every rotation of the pile tiles against the
+// pattern of the candidate positions. These benchmarks compare the string
+// based lookup it used to do with the packed one it does now.
+
+// matchesQueryString is the string comparison the solver used before
+// patterns were packed, kept to compare against
+func matchesQueryString(t *tile.Tile, query string) bool {
+	matches := func(border string, queryBorder byte) bool {
+		return queryBorder == '?' || border == string(queryBorder)
+	}
+	return matches(t.Top(), query[0]) && matches(t.Right(), query[1]) &&
+		matches(t.Bottom(), query[2]) && matches(t.Left(), query[3])
+}
+
+func BenchmarkHotPathStrings(b *testing.B) {
+	board, pile := newLargeBoard(20)
+
+	for b.Loop() {
+		for row := range board.Height() {
+			for col := range board.Width() {
+				query := board.GetTilePattern(row, col)
+				for _, t := range pile {
+					for _, rotated := range t.Rotations() {
+						matchesQueryString(&rotated, query)
+					}
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkHotPathPacked(b *testing.B) {
+	board, pile := newLargeBoard(20)
+
+	for b.Loop() {
+		for row := range board.Height() {
+			for col := range board.Width() {
+				pattern := board.PatternAt(row, col)
+				for _, t := range pile {
+					for _, rotated := range t.Rotations() {
+						rotated.Matches(pattern)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSegmentedSidesMatchMirrored(t *testing.T) {
+	drawing := "[    ]\n[(FFC)FFF]"
+	board := BoardFromString(drawing)
+	if board.String() != drawing {
+		t.Errorf("Expected the drawing back, got:\n%s", board.String())
+	}
+	if board.GetTilePattern(0, 0) != "??(CFF)?" {
+		t.Errorf("Expected the segments above the tile in mirrored order, got %s", board.GetTilePattern(0, 0))
+	}
+
+	for borders, solved := range map[string]bool{"(CFF)FFF": true, "(FFC)FFF": false} {
+		board := BoardFromString(drawing)
+		pile := Pile{tile.CreateTile(borders)}
+		if result := NewSolver(&board, &pile, SolverOptions{Propagate: true}).Solve(); result.Solved != solved {
+			t.Errorf("Expected solving with %s to be %t, got:\n%s", borders, solved, board.String())
+		}
+	}
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	board := BoardFromString("[FFCF][    ]\n[    ][    ]")
+	board.Features()
+	clone := board.Clone()
+
+	bottom := tile.CreateTile("CFFF")
+	if closed := clone.Place(1, 0, &bottom); len(closed) != 1 {
+		t.Errorf("Expected the copied city to be closed, got %v", closed)
+	}
+	if board.Get(1, 0) != nil || len(board.Features()) != 2 {
+		t.Errorf("Expected the board to be unchanged, got:\n%s\n%v", board.String(), board.Features())
+	}
+
+	// The copy untracks its own tiles
+	clone.Set(1, 0, nil)
+	clone.Set(0, 1, &bottom)
+	if expected := "[FFCF][CFFF]\n[    ][    ]"; clone.String() != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, clone.String())
+	}
+}
+
+func TestUndoRestoresTheBoard(t *testing.T) {
+	board := BoardFromString("[FFCF][    ]\n[    ][    ]")
+	before := board.String()
+	features := board.Features()
+
+	mark := board.Mark()
+	bottom, other := tile.CreateTile("CFFF"), tile.CreateTile("FFFF")
+	board.Place(1, 0, &bottom)
+	board.Pin(0, 1, &other)
+	board.Set(1, 0, &other)
+	board.Block(1, 1)
+
+	board.Undo(mark)
+	if board.String() != before || board.Blocked(1, 1) || board.Pinned(0, 1) {
+		t.Errorf("Expected the board to be restored, got:\n%s", board.String())
+	}
+	if !reflect.DeepEqual(board.Features(), features) {
+		t.Errorf("Expected the features to be restored, got %v", board.Features())
+	}
+
+	// A cell blocked before the mark stays blocked
+	board.Block(1, 1)
+	mark = board.Mark()
+	board.Block(1, 1)
+	board.Undo(mark)
+	if !board.Blocked(1, 1) {
+		t.Errorf("Expected the cell blocked before the mark to stay blocked")
+	}
+
+	// Undoing to a later mark keeps the earlier changes
+	board.Place(1, 0, &bottom)
+	mark = board.Mark()
+	board.Set(1, 0, nil)
+	board.Undo(mark)
+	if board.Get(1, 0) == nil {
+		t.Errorf("Expected the tile placed before the mark to be back")
+	}
+}
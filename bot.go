@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+)
+
+// Bot plays a game in place of a person
+type Bot interface {
+	// Choose returns one of the legal moves of the current player. It must
+	// not change the game.
+	Choose(game *Game) Move
+}
+
+var botNames = []string{"random", "greedy", "mcts"}
+
+// NewBot returns the bot with the given name, drawing its random choices
+// from rng
+func NewBot(name string, rng *rand.Rand) (Bot, error) {
+	switch name {
+	case "random":
+		return &RandomBot{Rand: rng}, nil
+	case "greedy":
+		return &GreedyBot{Rand: rng}, nil
+	case "mcts":
+		return &MCTSBot{Rand: rng, Iterations: DefaultMCTSIterations}, nil
+	}
+	return nil, fmt.Errorf("unknown bot %q, expected one of %v", name, botNames)
+}
+
+// RandomBot makes any of the legal moves
+type RandomBot struct {
+	Rand *rand.Rand
+}
+
+func (b *RandomBot) Choose(game *Game) Move {
+	moves := game.LegalMoves()
+	return moves[b.Rand.Intn(len(moves))]
+}
+
+// GreedyBot makes the move that leaves it furthest ahead of the best other
+// player if the game ended right after it, counting the points of completed
+// features and what its meeples would score
+type GreedyBot struct {
+	Rand *rand.Rand
+}
+
+func (b *GreedyBot) Choose(game *Game) Move {
+	var best []Move
+	bestMargin := math.MinInt
+	next := game.clone()
+	mark := next.mark()
+	for _, move := range game.LegalMoves() {
+		next.play(move)
+		margin := lead(next.projectedScores(), game.Current())
+		next.undo(mark)
+		if margin > bestMargin {
+			best, bestMargin = nil, margin
+		}
+		if margin == bestMargin {
+			best = append(best, move)
+		}
+	}
+	return best[b.Rand.Intn(len(best))]
+}
+
+// lead returns how far a player is ahead of the best other player
+func lead(scores []int, player int) int {
+	others := math.MinInt
+	for i, score := range scores {
+		if i != player {
+			others = max(others, score)
+		}
+	}
+	if others == math.MinInt {
+		return scores[player] // a game on one's own
+	}
+	return scores[player] - others
+}
+
+// DefaultMCTSIterations is the number of games an MCTSBot plays out for
+// every move
+const DefaultMCTSIterations = 200
+
+// MCTSBot searches the moves with Monte Carlo tree search. The order of the
+// pile is hidden from the players, so every iteration plays on a copy of the
+// game with the rest of the pile shuffled, and the tree only follows moves
+// that are legal with the tiles drawn in that copy. The copy is taken once
+// per move and undone after every iteration.
+type MCTSBot struct {
+	Rand       *rand.Rand
+	Iterations int
+
+	// RolloutDepth limits the random moves played after the tree, scoring
+	// the game as if it ended there; 0 plays every game to the end
+	RolloutDepth int
+}
+
+// mctsNode is a move of the search tree, with the results of the games that
+// went through it
+type mctsNode struct {
+	move     Move
+	player   int // who made the move
+	children []*mctsNode
+
+	visits int
+	wins   float64
+
+	// available counts the iterations the move was legal in, which takes
+	// the place of the visits of the parent when choosing among children
+	available int
+}
+
+func (b *MCTSBot) Choose(game *Game) Move {
+	root := &mctsNode{}
+	state := game.clone()
+	mark := state.mark()
+	for range max(b.Iterations, 1) {
+		b.iterate(root, state)
+		state.undo(mark)
+	}
+
+	best := root.children[0]
+	for _, child := range root.children[1:] {
+		if child.visits > best.visits {
+			best = child
+		}
+	}
+	return best.move
+}
+
+// iterate plays a game on a copy after shuffling its pile: down the tree while
+// every legal move has been tried, then a new move and random moves after it.
+// The result goes back up the moves played.
+func (b *MCTSBot) iterate(root *mctsNode, state *Game) {
+	state.pile.Shuffle(b.Rand)
+
+	path := []*mctsNode{root}
+	node := root
+	for !state.Over() {
+		moves := state.LegalMoves()
+		var untried []Move
+		for _, move := range moves {
+			if !slices.ContainsFunc(node.children, func(child *mctsNode) bool { return child.move == move }) {
+				untried = append(untried, move)
+			}
+		}
+
+		if len(untried) > 0 {
+			child := &mctsNode{move: untried[b.Rand.Intn(len(untried))], player: state.Current()}
+			node.children = append(node.children, child)
+			child.available++
+			state.play(child.move)
+			path = append(path, child)
+			break
+		}
+
+		node = b.selectChild(node, moves)
+		state.play(node.move)
+		path = append(path, node)
+	}
+
+	scores := b.rollout(state)
+	for _, node := range path[1:] {
+		node.visits++
+		node.wins += share(scores, node.player)
+	}
+}
+
+// selectChild picks the child with the highest upper confidence bound among
+// the moves that are legal now
+func (b *MCTSBot) selectChild(node *mctsNode, moves []Move) *mctsNode {
+	var best *mctsNode
+	bestBound := math.Inf(-1)
+	for _, child := range node.children {
+		if !slices.Contains(moves, child.move) {
+			continue
+		}
+		child.available++
+		bound := child.wins/float64(child.visits) + math.Sqrt(2*math.Log(float64(child.available))/float64(child.visits))
+		if bound > bestBound {
+			best, bestBound = child, bound
+		}
+	}
+	return best
+}
+
+// rollout plays random moves until the game ends or the depth runs out and
+// returns the scores
+func (b *MCTSBot) rollout(state *Game) []int {
+	for depth := 0; !state.Over() && (b.RolloutDepth == 0 || depth < b.RolloutDepth); depth++ {
+		state.play(randomMove(state, b.Rand))
+	}
+	return state.projectedScores()
+}
+
+// randomMove picks a placement and puts a meeple on it half of the time. It
+// is much cheaper than choosing from LegalMoves, which tries every placement
+// on the board.
+func randomMove(game *Game, rng *rand.Rand) Move {
+	placements := game.placements()
+	move := placements[rng.Intn(len(placements))]
+	if game.players[game.current].Meeples > 0 && rng.Intn(2) == 0 {
+		if moves := game.meepleMoves(move); len(moves) > 0 {
+			move = moves[rng.Intn(len(moves))]
+		}
+	}
+	return move
+}
+
+// share returns the part of a win a player gets: 1 for the best score,
+// split between the players that tie for it
+func share(scores []int, player int) float64 {
+	best := slices.Max(scores)
+	if scores[player] != best {
+		return 0
+	}
+	winners := 0
+	for _, score := range scores {
+		if score == best {
+			winners++
+		}
+	}
+	return 1 / float64(winners)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/vakrim/carcassonne-wave-collapse/scoring"
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+func TestGreedyBotCompletesCity(t *testing.T) {
+	board := BoardFromString("[    ][    ]\n[CFFF][    ]")
+	pile := Pile{tile.CreateTile("FFCF")}
+	game, err := NewGame(&board, &pile, []string{"Ann", "Bob"}, scoring.DefaultRules)
+	if err != nil {
+		t.Fatalf("Expected the game to start, got error: %v", err)
+	}
+
+	bot := &GreedyBot{Rand: rand.New(rand.NewSource(1))}
+	move := bot.Choose(game)
+	if move.Row != 0 || move.Col != 0 || move.Meeple != featureIndex(move.Tile, tile.City) {
+		t.Errorf("Expected a meeple on the city closed above the start tile, got %+v", move)
+	}
+}
+
+func TestBotsPlayLegalMoves(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	bots := []Bot{
+		&RandomBot{Rand: rng},
+		&GreedyBot{Rand: rng},
+		&MCTSBot{Rand: rng, Iterations: 10, RolloutDepth: 4},
+	}
+
+	board := NewUnboundedBoard()
+	pile := Pile{
+		tile.CreateTile("CFRR"), tile.CreateTile("FRFR"), tile.CreateTile("CCFF"), tile.CreateTile("RRFF"),
+		tile.CreateTile("CFFF"), tile.CreateTile("FFRR"), tile.CreateTile("CRRF"),
+	}
+	start := pile.PopTop()
+	board.Pin(0, 0, &start)
+	game, err := NewGame(&board, &pile, []string{"Random", "Greedy", "MCTS"}, scoring.DefaultRules)
+	if err != nil {
+		t.Fatalf("Expected the game to start, got error: %v", err)
+	}
+	for !game.Over() {
+		move := bots[game.Current()].Choose(game)
+		if !slices.Contains(game.LegalMoves(), move) {
+			t.Fatalf("Expected a legal move from bot %d, got %+v", game.Current(), move)
+		}
+		if err := game.Play(move); err != nil {
+			t.Fatalf("Expected the move to be played, got error: %v", err)
+		}
+	}
+}
+
+func TestTournamentCountsEveryGame(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	setup := func() (*Board, *Pile, error) {
+		pile := Pile{tile.CreateTile("CFFF"), tile.CreateTile("FFCF"), tile.CreateTile("FRFR"), tile.CreateTile("CRRF")}
+		pile.Shuffle(rng)
+		board := NewUnboundedBoard()
+		start := pile.PopTop()
+		board.Pin(0, 0, &start)
+		return &board, &pile, nil
+	}
+
+	result, err := RunTournament([]string{"greedy", "random"}, []Bot{&GreedyBot{Rand: rng}, &RandomBot{Rand: rng}}, 4, setup, scoring.DefaultRules)
+	if err != nil {
+		t.Fatalf("Expected the tournament to finish, got error: %v", err)
+	}
+	if result.Games != 4 || result.Standings[0].Wins+result.Standings[1].Wins != 4 {
+		t.Errorf("Expected 4 games to be won between the bots, got %+v", result)
+	}
+	if rate := result.WinRate(0) + result.WinRate(1); rate != 1 {
+		t.Errorf("Expected the win rates to add up to 1, got %v", rate)
+	}
+}
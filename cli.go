@@ -0,0 +1,661 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vakrim/carcassonne-wave-collapse/scoring"
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+const cliUsage = `Usage: carcassonne-wave-collapse <command> [flags]
+
+Commands:
+  visualize  Solve the board in a window, showing every step (default)
+  solve      Solve the board without a display and print the result
+  validate   Check a tile file and the board setup without solving
+  generate   Write a file of random tiles
+  score      Score a board file with the rules of Carcassonne
+  play       Play Carcassonne with the tiles, taking turns at one screen
+  tournament Play games between bots without a display and report win rates
+
+Run "carcassonne-wave-collapse <command> -h" for the flags of a command.
+`
+
+type cliCommand struct {
+	name string
+	run  func(args []string, out io.Writer) error
+}
+
+var cliCommands = []cliCommand{
+	{"visualize", runVisualizeCommand},
+	{"solve", runSolveCommand},
+	{"validate", runValidateCommand},
+	{"generate", runGenerateCommand},
+	{"score", runScoreCommand},
+	{"play", runPlayCommand},
+	{"tournament", runTournamentCommand},
+}
+
+func runCLI(args []string, out io.Writer) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		args = append([]string{"visualize"}, args...)
+	}
+
+	for _, command := range cliCommands {
+		if command.name == args[0] {
+			err := command.run(args[1:], out)
+			if errors.Is(err, flag.ErrHelp) {
+				return nil // usage has already been printed by the flag set
+			}
+			return err
+		}
+	}
+
+	if args[0] == "help" {
+		fmt.Fprint(out, cliUsage)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q\n\n%s", args[0], cliUsage)
+}
+
+// boardOptions holds the flags shared by every command that sets up a board
+type boardOptions struct {
+	tilesFile string
+	width     int
+	height    int
+	startRow  int
+	startCol  int
+	unbounded bool
+	shape     string
+	boardFile string
+	subtract  bool
+	edges     string
+	topology  string
+	borders   string
+}
+
+func (o *boardOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.tilesFile, "tiles", "tiles.txt", "file with one tile pattern per line")
+	fs.IntVar(&o.width, "width", 12, "board width in tiles")
+	fs.IntVar(&o.height, "height", 12, "board height in tiles")
+	fs.IntVar(&o.startRow, "start-row", 6, "row of the first tile")
+	fs.IntVar(&o.startCol, "start-col", 6, "column of the first tile")
+	fs.BoolVar(&o.unbounded, "unbounded", false, "let the board grow in every direction, ignoring -width and -height")
+	fs.StringVar(&o.shape, "shape", "rectangle", "cells of the board tiles may go on: rectangle, circle, ring or l")
+	fs.StringVar(&o.boardFile, "board", "", "start from a partially filled board file, replacing -width, -height, -shape and the start tile")
+	fs.BoolVar(&o.subtract, "subtract-pinned", false, "take the tiles of the -board file out of the pile")
+	fs.StringVar(&o.topology, "topology", "square", "square, torus to join the opposite edges of the board, or hex for six-sided tiles")
+	fs.StringVar(&o.borders, "borders", "", "file of border types to use instead of F, C, S and R")
+	fs.StringVar(&o.edges, "edges", "open", "rule for the board edges: open, wrap or a border such as F, or four comma-separated rules for top, right, bottom and left")
+}
+
+// seedOptions holds the flags of commands that use randomness
+type seedOptions struct {
+	seed    int64
+	shuffle bool
+}
+
+func (o *seedOptions) register(fs *flag.FlagSet) {
+	fs.Int64Var(&o.seed, "seed", 0, "random seed, 0 picks one from the current time")
+}
+
+func (o *seedOptions) registerShuffle(fs *flag.FlagSet) {
+	fs.BoolVar(&o.shuffle, "shuffle", false, "shuffle the pile before placing the first tile")
+}
+
+// rand returns a generator for the chosen seed. The seed is resolved on the
+// first call so it can be reported and replayed afterwards.
+func (o *seedOptions) rand() *rand.Rand {
+	if o.seed == 0 {
+		o.seed = time.Now().UnixNano()
+	}
+	return rand.New(rand.NewSource(o.seed))
+}
+
+// solverFlags holds the flags that configure the Solver
+type solverFlags struct {
+	drawPolicy string
+	strategy   string
+	propagate  bool
+}
+
+func (o *solverFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.drawPolicy, "draw", "", "which tiles may be placed next: top, any or weighted (default top, or any for the cell strategy)")
+	fs.StringVar(&o.strategy, "strategy", "tile", "tile searches positions for the drawn tile, cell collapses the most constrained cell")
+	fs.BoolVar(&o.propagate, "propagate", true, "narrow per-cell domains after each placement to skip dead ends early")
+}
+
+func (o *solverFlags) options(rng *rand.Rand) (SolverOptions, error) {
+	strategy, err := ParseStrategy(o.strategy)
+	if err != nil {
+		return SolverOptions{}, err
+	}
+
+	drawPolicyName := o.drawPolicy
+	if drawPolicyName == "" {
+		drawPolicyName = DrawTop.String()
+		if strategy == CellFirst {
+			drawPolicyName = DrawAny.String()
+		}
+	}
+	drawPolicy, err := ParseDrawPolicy(drawPolicyName)
+	if err != nil {
+		return SolverOptions{}, err
+	}
+
+	return SolverOptions{Rand: rng, DrawPolicy: drawPolicy, Strategy: strategy, Propagate: o.propagate}, nil
+}
+
+func (o *boardOptions) validate() error {
+	shape, err := ParseBoardShape(o.shape)
+	if err != nil {
+		return err
+	}
+	edges, err := ParseEdges(o.edges)
+	if err != nil {
+		return err
+	}
+	if o.unbounded && edges != (Edges{}) {
+		return fmt.Errorf("an unbounded board cannot have edge rules")
+	}
+	switch o.topology {
+	case "square":
+	case "torus":
+		if o.unbounded {
+			return fmt.Errorf("an unbounded board cannot be a torus")
+		}
+		if edges != (Edges{}) {
+			return fmt.Errorf("the edges of a torus are always joined, -edges cannot be used with it")
+		}
+	case "hex":
+		if edges != (Edges{}) {
+			return fmt.Errorf("edge rules only apply to square boards, -edges cannot be used with hex")
+		}
+		if shape != ShapeRectangle {
+			return fmt.Errorf("a hex board cannot have the %s shape", shape)
+		}
+	default:
+		return fmt.Errorf("unknown topology %q, expected square, torus or hex", o.topology)
+	}
+	if o.boardFile != "" {
+		return nil // the file decides the board size and the placed tiles
+	}
+	if o.subtract {
+		return fmt.Errorf("-subtract-pinned needs a -board file")
+	}
+	if o.unbounded {
+		if shape != ShapeRectangle {
+			return fmt.Errorf("an unbounded board cannot have the %s shape", shape)
+		}
+		return nil // any start position is on the board
+	}
+	if o.width <= 0 || o.height <= 0 {
+		return fmt.Errorf("board size must be positive, got %dx%d", o.width, o.height)
+	}
+	if o.startRow < 0 || o.startRow >= o.height || o.startCol < 0 || o.startCol >= o.width {
+		return fmt.Errorf("start position [%d][%d] is outside the %dx%d board",
+			o.startRow, o.startCol, o.width, o.height)
+	}
+	if !shape.contains(o.startRow, o.startCol, o.width, o.height) {
+		return fmt.Errorf("start position [%d][%d] is not part of the %s shape", o.startRow, o.startCol, shape)
+	}
+	return nil
+}
+
+// loadBorders registers the border types of the -borders file, if any
+func (o *boardOptions) loadBorders() error {
+	if o.borders == "" {
+		return nil
+	}
+	if err := loadBorderTypesFromFile(o.borders); err != nil {
+		return fmt.Errorf("loading borders: %w", err)
+	}
+	return nil
+}
+
+// newBoard loads the -board file, or creates the empty board described by
+// the other flags, which must be valid
+func (o *boardOptions) newBoard() (Board, error) {
+	var board Board
+	if o.boardFile != "" {
+		var err error
+		board, err = loadBoardFromFile(o.boardFile, o.unbounded)
+		if err != nil {
+			return Board{}, fmt.Errorf("loading board: %w", err)
+		}
+		if len(board.tiles) == 0 {
+			return Board{}, fmt.Errorf("%s has no tiles to start from", o.boardFile)
+		}
+		if o.topology == "hex" && board.Sides() != int(tile.HexSideCount) {
+			return Board{}, fmt.Errorf("%s is not a hex board", o.boardFile)
+		}
+	} else if o.topology == "hex" && o.unbounded {
+		board = NewUnboundedHexBoard()
+	} else if o.topology == "hex" {
+		board = NewHexBoard(o.width, o.height)
+	} else if o.unbounded {
+		board = NewUnboundedBoard()
+	} else {
+		shape, _ := ParseBoardShape(o.shape)
+		board = NewShapedBoard(o.width, o.height, shape)
+	}
+
+	edges, _ := ParseEdges(o.edges)
+	if o.topology == "torus" {
+		edges, _ = ParseEdges("wrap")
+	}
+	if err := board.SetEdges(edges); err != nil {
+		return Board{}, err
+	}
+	return board, nil
+}
+
+// setup loads the pile, shuffles it if requested and puts its top tile on
+// the start position, unless the board comes from a file
+func (o *boardOptions) setup(rng *rand.Rand, shuffle bool) (*Board, *Pile, error) {
+	if err := o.loadBorders(); err != nil {
+		return nil, nil, err
+	}
+	if err := o.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	pile, err := loadTilesFromFile(o.tilesFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading tiles: %w", err)
+	}
+	if !pile.hasMoreTiles() {
+		return nil, nil, fmt.Errorf("no tiles in %s", o.tilesFile)
+	}
+	if shuffle {
+		pile.Shuffle(rng)
+	}
+
+	board, err := o.newBoard()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := pile.CheckSides(board.Sides()); err != nil {
+		return nil, nil, err
+	}
+	if o.boardFile == "" {
+		start := pile.PopTop()
+		board.Pin(o.startRow, o.startCol, &start)
+	} else if o.subtract {
+		if err := pile.RemovePinnedTiles(&board); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return &board, &pile, nil
+}
+
+func runVisualizeCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("visualize", flag.ContinueOnError)
+	var options boardOptions
+	var seed seedOptions
+	var solver solverFlags
+	options.register(fs)
+	seed.register(fs)
+	seed.registerShuffle(fs)
+	solver.register(fs)
+	startDelay := fs.Duration("start-delay", time.Second, "wait before the solver starts")
+	stepDelay := fs.Duration("delay", 500*time.Millisecond, "initial pause after each placement")
+	tiled := fs.Bool("tiled", false, "draw the board 2x2 to check the seams of a wrapping board (toggle with T)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	rng := seed.rand()
+	solverOptions, err := solver.options(rng)
+	if err != nil {
+		return err
+	}
+	board, pile, err := options.setup(rng, seed.shuffle)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Loaded %d tiles from file\n", pile.Size())
+	fmt.Fprintf(out, "Seed: %d\n", seed.seed)
+	fmt.Fprintln(out, "Starting visualization...")
+
+	return runVisualization(board, pile, solverOptions, seed.seed, *startDelay, *stepDelay, *tiled)
+}
+
+func runSolveCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("solve", flag.ContinueOnError)
+	var options boardOptions
+	var seed seedOptions
+	var solver solverFlags
+	options.register(fs)
+	seed.register(fs)
+	seed.registerShuffle(fs)
+	solver.register(fs)
+	format := fs.String("format", "text", "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown output format %q", *format)
+	}
+
+	rng := seed.rand()
+	solverOptions, err := solver.options(rng)
+	if err != nil {
+		return err
+	}
+	board, pile, err := options.setup(rng, seed.shuffle)
+	if err != nil {
+		return err
+	}
+
+	result := NewSolver(board, pile, solverOptions).Solve()
+
+	if *format == "json" {
+		err = writeSolveJSON(out, board, result, seed.seed, solverOptions)
+	} else {
+		err = writeSolveText(out, board, result, seed.seed, solverOptions)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !result.Solved {
+		return fmt.Errorf("could not place all tiles: %w", result.Err)
+	}
+	return nil
+}
+
+func writeSolveText(out io.Writer, board *Board, result SolveResult, seed int64, options SolverOptions) error {
+	_, err := fmt.Fprintf(out, "%s\nSeed: %d, strategy: %s, draw: %s, propagate: %t\nPlacements: %d, backtracks: %d, pruned: %d, time: %v\n",
+		board.String(), seed, options.Strategy, options.DrawPolicy, options.Propagate,
+		result.Stats.Placements, result.Stats.Backtracks, result.Stats.Pruned, result.Stats.Duration)
+	return err
+}
+
+type solveOutput struct {
+	Seed           int64    `json:"seed"`
+	Strategy       string   `json:"strategy"`
+	DrawPolicy     string   `json:"draw"`
+	Propagate      bool     `json:"propagate"`
+	Solved         bool     `json:"solved"`
+	Error          string   `json:"error,omitempty"`
+	TilesRemaining int      `json:"tilesRemaining"`
+	Placements     int      `json:"placements"`
+	Backtracks     int      `json:"backtracks"`
+	Pruned         int      `json:"pruned"`
+	MaxDepth       int      `json:"maxDepth"`
+	DurationMs     float64  `json:"durationMs"`
+	Board          []string `json:"board"`
+}
+
+func writeSolveJSON(out io.Writer, board *Board, result SolveResult, seed int64, options SolverOptions) error {
+	output := solveOutput{
+		Seed:           seed,
+		Strategy:       options.Strategy.String(),
+		DrawPolicy:     options.DrawPolicy.String(),
+		Propagate:      options.Propagate,
+		Solved:         result.Solved,
+		TilesRemaining: result.TilesRemaining,
+		Placements:     result.Stats.Placements,
+		Backtracks:     result.Stats.Backtracks,
+		Pruned:         result.Stats.Pruned,
+		MaxDepth:       result.Stats.MaxDepth,
+		DurationMs:     float64(result.Stats.Duration.Microseconds()) / 1000,
+		Board:          strings.Split(board.String(), "\n"),
+	}
+	if result.Err != nil {
+		output.Error = result.Err.Error()
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(output)
+}
+
+func runValidateCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	var options boardOptions
+	options.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := options.loadBorders(); err != nil {
+		return err
+	}
+	if err := options.validate(); err != nil {
+		return err
+	}
+
+	pile, err := loadTilesFromFile(options.tilesFile)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "%s: %d tiles, %d distinct\n", options.tilesFile, pile.Size(), NewCountedPile(&pile).Distinct())
+	if !pile.hasMoreTiles() {
+		return fmt.Errorf("no tiles in %s", options.tilesFile)
+	}
+	board, err := options.newBoard()
+	if err != nil {
+		return err
+	}
+	if err := pile.CheckSides(board.Sides()); err != nil {
+		return err
+	}
+	if options.subtract {
+		if err := pile.RemovePinnedTiles(&board); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%d tiles left after taking out the pinned ones\n", pile.Size())
+	}
+	if board.Bounded() && pile.Size() > board.CellCount()-len(board.tiles) {
+		return fmt.Errorf("%d tiles do not fit in the %d empty cells of the board",
+			pile.Size(), board.CellCount()-len(board.tiles))
+	}
+
+	fmt.Fprintln(out, "OK")
+	return nil
+}
+
+func runGenerateCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	count := fs.Int("count", 50, "number of tiles to generate")
+	output := fs.String("o", "", "file to write the tiles to (default stdout)")
+	sides := fs.Int("sides", 4, "sides of every tile: 4, or 6 for a hex board")
+	borders := fs.String("borders", "", "file of border types to draw the borders from instead of F, C, S and R")
+	var seed seedOptions
+	seed.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *count <= 0 {
+		return errors.New("count must be positive")
+	}
+	if *sides != int(tile.SideCount) && *sides != int(tile.HexSideCount) {
+		return fmt.Errorf("tiles must have %d or %d sides, got %d", tile.SideCount, tile.HexSideCount, *sides)
+	}
+	if *borders != "" {
+		if err := loadBorderTypesFromFile(*borders); err != nil {
+			return fmt.Errorf("loading borders: %w", err)
+		}
+	}
+
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	rng := seed.rand()
+	if _, err := fmt.Fprintf(out, "# generated with seed %d\n", seed.seed); err != nil {
+		return err
+	}
+
+	for range *count {
+		t := tile.CreateRandomTileWithSides(rng, *sides)
+		if _, err := fmt.Fprintln(out, t.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runScoreCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("score", flag.ContinueOnError)
+	boardFile := fs.String("board", "", "board file to score, e.g. the output of solve")
+	unbounded := fs.Bool("unbounded", false, "read the board as part of an unbounded one, so features may still close past its edges")
+	borders := fs.String("borders", "", "file of border types the board uses instead of F, C, S and R")
+	format := fs.String("format", "text", "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *boardFile == "" {
+		return errors.New("-board is required")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown output format %q", *format)
+	}
+	if *borders != "" {
+		if err := loadBorderTypesFromFile(*borders); err != nil {
+			return fmt.Errorf("loading borders: %w", err)
+		}
+	}
+
+	board, err := loadBoardFromFile(*boardFile, *unbounded)
+	if err != nil {
+		return fmt.Errorf("loading board: %w", err)
+	}
+	features := board.ScoringFeatures()
+	score := scoring.DefaultRules.Score(features)
+
+	if *format == "json" {
+		return writeScoreJSON(out, features, score)
+	}
+	for _, featureScore := range score.Features {
+		if _, err := fmt.Fprintln(out, featureScore.Describe(features[featureScore.Feature])); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(out, "Total: %d points\n", score.Total)
+	return err
+}
+
+type featureScoreOutput struct {
+	Kind     string `json:"kind"`
+	Label    string `json:"label"`
+	Tiles    int    `json:"tiles"`
+	Pennants int    `json:"pennants"`
+	Complete bool   `json:"complete"`
+	Points   int    `json:"points"`
+}
+
+type scoreOutput struct {
+	Features []featureScoreOutput `json:"features"`
+	Total    int                  `json:"total"`
+}
+
+func writeScoreJSON(out io.Writer, features []scoring.Feature, score scoring.Score) error {
+	output := scoreOutput{Features: []featureScoreOutput{}, Total: score.Total}
+	for _, featureScore := range score.Features {
+		feature := features[featureScore.Feature]
+		output.Features = append(output.Features, featureScoreOutput{
+			Kind:     feature.Kind.String(),
+			Label:    feature.Label,
+			Tiles:    feature.Tiles,
+			Pennants: feature.Pennants,
+			Complete: feature.Complete,
+			Points:   featureScore.Points,
+		})
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(output)
+}
+
+func runPlayCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("play", flag.ContinueOnError)
+	var options boardOptions
+	var seed seedOptions
+	options.register(fs)
+	seed.register(fs)
+	seed.registerShuffle(fs)
+	players := fs.String("players", "Player 1,Player 2", "comma-separated names of the players, in turn order")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	rng := seed.rand()
+	board, pile, err := options.setup(rng, seed.shuffle)
+	if err != nil {
+		return err
+	}
+	// The game draws from the pile as soon as it starts
+	tiles := pile.Size()
+	game, err := NewGame(board, pile, strings.Split(*players, ","), scoring.DefaultRules)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Loaded %d tiles from file\n", tiles)
+	fmt.Fprintf(out, "Seed: %d\n", seed.seed)
+	return runHotSeat(game, out)
+}
+
+func runTournamentCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("tournament", flag.ContinueOnError)
+	var options boardOptions
+	var seed seedOptions
+	options.register(fs)
+	seed.register(fs)
+	botList := fs.String("bots", "greedy,random", fmt.Sprintf("comma-separated bots to play each other, of %v", botNames))
+	games := fs.Int("games", 10, "number of games to play")
+	iterations := fs.Int("mcts-iterations", DefaultMCTSIterations, "games the mcts bot plays out for every move")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *games < 1 {
+		return fmt.Errorf("-games must be at least 1, got %d", *games)
+	}
+
+	rng := seed.rand()
+	names := strings.Split(*botList, ",")
+	bots := make([]Bot, len(names))
+	for i, name := range names {
+		bot, err := NewBot(name, rng)
+		if err != nil {
+			return err
+		}
+		if mcts, ok := bot.(*MCTSBot); ok {
+			mcts.Iterations = *iterations
+		}
+		bots[i] = bot
+	}
+
+	// Every game starts from a freshly shuffled pile
+	setup := func() (*Board, *Pile, error) {
+		return options.setup(rng, true)
+	}
+	result, err := RunTournament(names, bots, *games, setup, scoring.DefaultRules)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Seed: %d\n", seed.seed)
+	result.Write(out)
+	return nil
+}
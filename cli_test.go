@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+func writeTilesFile(t *testing.T, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "tiles.txt")
+	if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+		t.Fatalf("Could not write tiles file: %v", err)
+	}
+	return filename
+}
+
+func TestSolveCommandJSON(t *testing.T) {
+	filename := writeTilesFile(t, "CFFF\nFFCF\n\nRFRF\n")
+
+	var out bytes.Buffer
+	err := runCLI([]string{"solve", "-tiles", filename, "-width", "3", "-height", "3",
+		"-start-row", "1", "-start-col", "1", "-format", "json"}, &out)
+	if err != nil {
+		t.Fatalf("Expected solve to succeed, got error: %v", err)
+	}
+
+	var output solveOutput
+	if err := json.Unmarshal(out.Bytes(), &output); err != nil {
+		t.Fatalf("Could not parse JSON output: %v\n%s", err, out.String())
+	}
+	if !output.Solved || output.TilesRemaining != 0 {
+		t.Errorf("Expected a solved board, got %+v", output)
+	}
+	if len(output.Board) != 3 || !strings.Contains(output.Board[1], "[CFFF]") {
+		t.Errorf("Expected a 3 row board with the start tile in the middle, got %v", output.Board)
+	}
+}
+
+func TestSolveCommandWithPinnedBoard(t *testing.T) {
+	tiles := writeTilesFile(t, "FFCF\nRFRF\nCFFF\nFFFF\n")
+	board := writeTilesFile(t, "[    ][    ][    ]\n[    ][CFFF][    ]\n[    ][    ][RFRF]\n")
+
+	var out bytes.Buffer
+	err := runCLI([]string{"solve", "-tiles", tiles, "-board", board, "-subtract-pinned", "-draw", "any", "-format", "json"}, &out)
+	if err != nil {
+		t.Fatalf("Expected solve to succeed, got error: %v", err)
+	}
+
+	var output solveOutput
+	if err := json.Unmarshal(out.Bytes(), &output); err != nil {
+		t.Fatalf("Could not parse JSON output: %v\n%s", err, out.String())
+	}
+	if !output.Solved || output.Placements-output.Backtracks != 2 {
+		t.Errorf("Expected the two tiles left in the pile to be placed, got %+v", output)
+	}
+	if len(output.Board) != 3 || output.Board[1][6:12] != "[CFFF]" || output.Board[2][12:] != "[RFRF]" {
+		t.Errorf("Expected the pinned tiles to stay in place, got %v", output.Board)
+	}
+
+	missing := writeTilesFile(t, "FFFF\nCFFF\n")
+	if err := runCLI([]string{"validate", "-tiles", missing, "-board", board, "-subtract-pinned"}, &out); err == nil ||
+		!strings.Contains(err.Error(), "RFRF") {
+		t.Errorf("Expected an error for a pinned tile missing from the pile, got %v", err)
+	}
+}
+
+func TestValidateCommand(t *testing.T) {
+	var out bytes.Buffer
+
+	valid := writeTilesFile(t, "CFFF\nCFFF\nRFRF\n")
+	if err := runCLI([]string{"validate", "-tiles", valid}, &out); err != nil {
+		t.Errorf("Expected a valid tiles file, got error: %v", err)
+	}
+	if !strings.Contains(out.String(), "3 tiles, 2 distinct") {
+		t.Errorf("Expected a tile summary, got %q", out.String())
+	}
+
+	invalid := writeTilesFile(t, "CFFF\nCXFF\n")
+	err := runCLI([]string{"validate", "-tiles", invalid}, &out)
+	if err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("Expected an error pointing at line 2, got %v", err)
+	}
+
+	tooMany := writeTilesFile(t, "CFFF\nCFFF\nCFFF\n")
+	if err := runCLI([]string{"validate", "-tiles", tooMany, "-width", "1", "-height", "2", "-start-row", "0", "-start-col", "0"}, &out); err == nil {
+		t.Errorf("Expected an error when the tiles do not fit on the board")
+	}
+
+	if err := runCLI([]string{"validate", "-tiles", valid, "-start-row", "12"}, &out); err == nil {
+		t.Errorf("Expected an error for a start position outside the board")
+	}
+}
+
+func TestGenerateCommand(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "generated.txt")
+
+	if err := runCLI([]string{"generate", "-count", "7", "-o", filename}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Expected generate to succeed, got error: %v", err)
+	}
+
+	pile, err := loadTilesFromFile(filename)
+	if err != nil {
+		t.Fatalf("Expected generated tiles to load, got error: %v", err)
+	}
+	if pile.Size() != 7 {
+		t.Errorf("Expected 7 generated tiles, got %d", pile.Size())
+	}
+
+	var first, second bytes.Buffer
+	for _, out := range []*bytes.Buffer{&first, &second} {
+		if err := runCLI([]string{"generate", "-count", "20", "-seed", "99"}, out); err != nil {
+			t.Fatalf("Expected generate to succeed, got error: %v", err)
+		}
+	}
+	if first.String() != second.String() {
+		t.Errorf("Expected the same tiles for the same seed, got:\n%s\nand:\n%s", first.String(), second.String())
+	}
+	if !strings.HasPrefix(first.String(), "# generated with seed 99\n") {
+		t.Errorf("Expected the seed to be recorded in the output, got:\n%s", first.String())
+	}
+}
+
+func TestCommandsWithCustomBorders(t *testing.T) {
+	t.Cleanup(func() { tile.SetBorderTypes(tile.DefaultBorderTypes) })
+	tiles := writeTilesFile(t, "MLML\nFFFF\n")
+
+	var out bytes.Buffer
+	if err := runCLI([]string{"validate", "-tiles", tiles, "-borders", "lakes-borders.txt"}, &out); err != nil {
+		t.Errorf("Expected tiles with lakes and mountains to be valid, got error: %v", err)
+	}
+
+	tile.SetBorderTypes(tile.DefaultBorderTypes)
+	if err := runCLI([]string{"validate", "-tiles", tiles}, &out); err == nil || !strings.Contains(err.Error(), "unknown border type 'M'") {
+		t.Errorf("Expected an error for unknown borders, got %v", err)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	if err := runCLI([]string{"explode"}, &bytes.Buffer{}); err == nil {
+		t.Errorf("Expected an error for an unknown command")
+	}
+}
+
+func TestSolveCommandReplaysSeed(t *testing.T) {
+	filename := writeTilesFile(t, "CFFF\nFFCF\nRFRF\nFFFF\nCCFF\nRCRC\nSSSS\nSFSF\n")
+	args := []string{"solve", "-tiles", filename, "-seed", "12345", "-shuffle", "-format", "json"}
+
+	var first, second bytes.Buffer
+	for _, out := range []*bytes.Buffer{&first, &second} {
+		// The shuffled pile may leave the board unsolved, which is still
+		// reported in full
+		if err := runCLI(args, out); err != nil && !strings.Contains(err.Error(), "could not place all tiles") {
+			t.Fatalf("Expected solve to succeed or report the unsolved board, got error: %v", err)
+		}
+	}
+
+	var firstOutput, secondOutput solveOutput
+	if err := json.Unmarshal(first.Bytes(), &firstOutput); err != nil {
+		t.Fatalf("Could not parse JSON output: %v\n%s", err, first.String())
+	}
+	if err := json.Unmarshal(second.Bytes(), &secondOutput); err != nil {
+		t.Fatalf("Could not parse JSON output: %v\n%s", err, second.String())
+	}
+
+	if firstOutput.Seed != 12345 {
+		t.Errorf("Expected the seed to be stored in the output, got %d", firstOutput.Seed)
+	}
+	if !reflect.DeepEqual(firstOutput.Board, secondOutput.Board) {
+		t.Errorf("Expected the same board for the same seed, got:\n%v\nand:\n%v", firstOutput.Board, secondOutput.Board)
+	}
+}
+
+func TestScoreCommand(t *testing.T) {
+	board := writeTilesFile(t, "[FFCF B+][FFFF M]\n[CFFF][    ]\n")
+
+	var out bytes.Buffer
+	if err := runCLI([]string{"score", "-board", board, "-unbounded"}, &out); err != nil {
+		t.Fatalf("Expected score to succeed, got error: %v", err)
+	}
+	for _, expected := range []string{"complete city at [0][0] of 2 tiles and 1 pennant: 6 points", "monastery at [0][1] of 3 tiles: 3 points", "Total: 15 points"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in the output, got:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestTournamentCommand(t *testing.T) {
+	filename := writeTilesFile(t, "CFFF\nFFCF\nFRFR\nRFRF\nCRRF\n")
+
+	var out bytes.Buffer
+	args := []string{"tournament", "-tiles", filename, "-unbounded", "-games", "2", "-bots", "greedy,mcts", "-mcts-iterations", "5", "-seed", "9"}
+	if err := runCLI(args, &out); err != nil {
+		t.Fatalf("Expected tournament to succeed, got error: %v", err)
+	}
+	for _, expected := range []string{"Seed: 9", "2 games", "greedy", "mcts"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in the output, got:\n%s", expected, out.String())
+		}
+	}
+
+	if err := runCLI([]string{"tournament", "-tiles", filename, "-bots", "clever"}, &out); err == nil {
+		t.Errorf("Expected an unknown bot to be rejected")
+	}
+}
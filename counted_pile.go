@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+// CountedPile is a pile variant that stores every tile type once together
+// with the number of copies left. Tiles that are rotations of each other are
+// the same type. Matching and counting go over the distinct types only, which
+// is much cheaper than going over every copy when the pile has many
+// duplicates.
+type CountedPile struct {
+	types  []tile.Tile // first copy of each type, in the order they were added
+	counts []int
+	index  map[tile.Code]int
+	size   int
+}
+
+func NewCountedPile(pile *Pile) *CountedPile {
+	cp := &CountedPile{index: make(map[tile.Code]int)}
+	for _, t := range *pile {
+		cp.Add(t)
+	}
+	return cp
+}
+
+func (cp *CountedPile) Size() int {
+	return cp.size
+}
+
+// Distinct returns the number of tile types with copies left
+func (cp *CountedPile) Distinct() int {
+	distinct := 0
+	for _, count := range cp.counts {
+		if count > 0 {
+			distinct++
+		}
+	}
+	return distinct
+}
+
+// Types returns one tile of every type with copies left, in the order the
+// types were first added
+func (cp *CountedPile) Types() []tile.Tile {
+	var types []tile.Tile
+	for i, t := range cp.types {
+		if cp.counts[i] > 0 {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// Copies returns the number of copies left of the type of t
+func (cp *CountedPile) Copies(t tile.Tile) int {
+	if i, ok := cp.index[t.TypeCode()]; ok {
+		return cp.counts[i]
+	}
+	return 0
+}
+
+func (cp *CountedPile) Add(t tile.Tile) {
+	i, ok := cp.index[t.TypeCode()]
+	if !ok {
+		i = len(cp.types)
+		cp.index[t.TypeCode()] = i
+		cp.types = append(cp.types, t)
+		cp.counts = append(cp.counts, 0)
+	}
+	cp.counts[i]++
+	cp.size++
+}
+
+// Remove takes one copy of the type of t out of the pile
+func (cp *CountedPile) Remove(t tile.Tile) {
+	i, ok := cp.index[t.TypeCode()]
+	if !ok || cp.counts[i] == 0 {
+		panic(fmt.Sprintf("Tile %s not found in the pile", t.String()))
+	}
+	cp.counts[i]--
+	cp.size--
+}
+
+// CountMatchingTiles counts the tiles that fit a query in any rotation, 0
+// when the query is malformed
+func (cp *CountedPile) CountMatchingTiles(query string) int {
+	pattern, err := tile.ParsePattern(query)
+	if err != nil {
+		return 0
+	}
+	return cp.CountMatching(pattern)
+}
+
+// CountMatching counts the tiles that fit a packed pattern in any rotation
+func (cp *CountedPile) CountMatching(pattern tile.Pattern) int {
+	count := 0
+	for i, t := range cp.types {
+		if cp.counts[i] > 0 && t.MatchesInAnyRotation(pattern) {
+			count += cp.counts[i]
+		}
+	}
+	return count
+}
+
+// Pile expands the counted pile back into a pile with every copy, grouped
+// by type
+func (cp *CountedPile) Pile() Pile {
+	pile := make(Pile, 0, cp.size)
+	for i, t := range cp.types {
+		for range cp.counts[i] {
+			pile = append(pile, t)
+		}
+	}
+	return pile
+}
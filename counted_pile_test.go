@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+func TestCountedPileGroupsRotatedCopies(t *testing.T) {
+	pile := Pile{
+		tile.CreateTile("RSRS"),
+		tile.CreateTile("CCFF"),
+		tile.CreateTile("SRSR"),
+		tile.CreateTile("FCCF"),
+		tile.CreateTile("RSRS"),
+	}
+
+	counted := NewCountedPile(&pile)
+	if counted.Size() != 5 || counted.Distinct() != 2 {
+		t.Fatalf("Expected 5 tiles of 2 types, got %d tiles of %d types", counted.Size(), counted.Distinct())
+	}
+	if copies := counted.Copies(tile.CreateTile("SRSR")); copies != 3 {
+		t.Errorf("Expected 3 copies of RSRS, got %d", copies)
+	}
+
+	counted.Remove(tile.CreateTile("CCFF"))
+	counted.Remove(tile.CreateTile("CCFF"))
+	if counted.Distinct() != 1 || len(counted.Types()) != 1 {
+		t.Errorf("Expected CCFF to be gone, got types %v", counted.Types())
+	}
+	counted.Add(tile.CreateTile("CFFC"))
+	if counted.Copies(tile.CreateTile("CCFF")) != 1 || counted.Size() != 4 {
+		t.Errorf("Expected CCFF to be back, got %d tiles", counted.Size())
+	}
+}
+
+func TestCountedPileCountsLikePile(t *testing.T) {
+	pile := Pile{
+		tile.CreateTile("CFFF"),
+		tile.CreateTile("CFFF"),
+		tile.CreateTile("RFRF"),
+		tile.CreateTile("CCFF"),
+		tile.CreateTile("FFFF"),
+	}
+	counted := NewCountedPile(&pile)
+
+	queries := []string{"????", "C???", "?R??", "CC??", "F?F?", "SSSS", "X???"}
+	for _, query := range queries {
+		if expected, got := pile.CountMatchingTiles(query), counted.CountMatchingTiles(query); got != expected {
+			t.Errorf("Expected %d tiles to match %s, got %d", expected, query, got)
+		}
+	}
+
+	board := BoardFromString("[    ][CFRF][    ]")
+	if expected, got := board.CountPossibilities(&pile), board.CountPossibilities(counted); !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected possibilities %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+type cellPosition struct {
+	row, col int
+}
+
+// domain is a bit set of option indexes
+type domain []uint64
+
+func newDomain(options int) domain {
+	return make(domain, (options+63)/64)
+}
+
+func (d domain) has(option int) bool {
+	return d[option/64]&(1<<(option%64)) != 0
+}
+
+func (d domain) set(option int) {
+	d[option/64] |= 1 << (option % 64)
+}
+
+func (d domain) clear(option int) {
+	d[option/64] &^= 1 << (option % 64)
+}
+
+func (d domain) count() int {
+	count := 0
+	for _, word := range d {
+		count += bits.OnesCount64(word)
+	}
+	return count
+}
+
+func (d domain) intersects(other domain) bool {
+	for i := range d {
+		if d[i]&other[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Domains keeps, for every empty cell, the set of tile types (in each of their
+// orientations) that can still go there, and narrows these sets AC-3 style
+// after every placement so that dead ends are found before the solver goes
+// down them.
+//
+// Cells that no constraint has reached yet are not stored and implicitly allow
+// every tile type left in the pile. When the pile is large enough to fill the
+// whole board, every empty cell must get a tile: domains are then also
+// narrowed against the domains of empty neighbours, and an empty domain is a
+// contradiction. Otherwise cells may stay empty, and the contradiction is a
+// tile type left in the pile that no cell can take any more.
+type Domains struct {
+	board *Board
+
+	options    []tile.Tile       // every distinct orientation of every tile type in the pile
+	optionType []int             // tile type of each option
+	typeIndex  map[tile.Code]int // tile type of each type code
+	remaining  []int             // copies left in the pile of each tile type
+	compatible [][]domain        // options that may touch each option on each side
+
+	cells      map[cellPosition]domain
+	support    []int // number of stored cells that allow each option
+	emptyCells int   // only counted on a bounded board
+	fillBoard  bool
+
+	// trail holds the undo steps of every change, see Mark and Undo
+	trail []func()
+}
+
+// NewDomains builds the domains of all empty cells of the board for the tiles
+// in the pile. It returns an error if the board is already a dead end.
+func NewDomains(board *Board, pile *Pile) (*Domains, error) {
+	d := &Domains{
+		board:     board,
+		typeIndex: make(map[tile.Code]int),
+		cells:     make(map[cellPosition]domain),
+	}
+
+	counted := NewCountedPile(pile)
+	for typeIndex, t := range counted.Types() {
+		d.typeIndex[t.TypeCode()] = typeIndex
+		d.remaining = append(d.remaining, counted.Copies(t))
+
+		// Symmetric tiles look the same in several rotations
+		for _, rotated := range t.DistinctRotations() {
+			d.options = append(d.options, rotated)
+			d.optionType = append(d.optionType, typeIndex)
+		}
+	}
+
+	d.support = make([]int, len(d.options))
+	sides := board.Sides()
+	d.compatible = make([][]domain, len(d.options))
+	for a := range d.options {
+		d.compatible[a] = make([]domain, sides)
+		for side := range tile.Side(sides) {
+			d.compatible[a][side] = newDomain(len(d.options))
+			for b := range d.options {
+				if d.options[a].Segments(side).CanTouch(d.options[b].Segments(side.OppositeIn(sides))) {
+					d.compatible[a][side].set(b)
+				}
+			}
+		}
+	}
+
+	queue := d.constrainEdges()
+	for pos := range board.tiles {
+		queue = append(queue, d.constrainNeighbours(pos.row, pos.col)...)
+	}
+	if board.Bounded() {
+		d.emptyCells = board.CellCount() - len(board.tiles)
+		d.fillBoard = pile.Size() >= d.emptyCells
+	}
+
+	if err := d.propagate(queue); err != nil {
+		return nil, err
+	}
+	if err := d.check(); err != nil {
+		return nil, err
+	}
+	d.trail = nil // the initial state cannot be undone
+	return d, nil
+}
+
+// Size returns the number of tile orientations that can still go in a cell
+func (d *Domains) Size(row, col int) int {
+	if d.board.Get(row, col) != nil || !d.board.Contains(row, col) {
+		return 0
+	}
+	if cell, ok := d.cells[cellPosition{row, col}]; ok {
+		return cell.count()
+	}
+	return d.full().count()
+}
+
+// Mark returns the current position in the undo trail
+func (d *Domains) Mark() int {
+	return len(d.trail)
+}
+
+// Undo reverts every change made since the given mark
+func (d *Domains) Undo(mark int) {
+	for i := len(d.trail) - 1; i >= mark; i-- {
+		d.trail[i]()
+	}
+	d.trail = d.trail[:mark]
+}
+
+// Place updates the domains after t has been put on the board at the given
+// position and taken out of the pile. It returns an error when the placement
+// leads to a contradiction; the caller should then Undo to its mark.
+func (d *Domains) Place(row, col int, t *tile.Tile) error {
+	typeIndex, ok := d.typeIndex[t.TypeCode()]
+	if !ok || d.remaining[typeIndex] == 0 {
+		panic(fmt.Sprintf("Tile %s is not in the pile", t.String()))
+	}
+
+	pos := cellPosition{row, col}
+	if cell, ok := d.cells[pos]; ok {
+		for option := range d.options {
+			if cell.has(option) {
+				d.remove(cell, option)
+			}
+		}
+		delete(d.cells, pos)
+		d.trail = append(d.trail, func() { d.cells[pos] = cell })
+	}
+	d.emptyCells--
+	d.trail = append(d.trail, func() { d.emptyCells++ })
+
+	d.remaining[typeIndex]--
+	d.trail = append(d.trail, func() { d.remaining[typeIndex]++ })
+
+	var queue []cellPosition
+	if d.remaining[typeIndex] == 0 {
+		// The last copy is gone, so no cell can take this tile type any more
+		for pos, cell := range d.cells {
+			changed := false
+			for option := range d.options {
+				if d.optionType[option] == typeIndex && cell.has(option) {
+					d.remove(cell, option)
+					changed = true
+				}
+			}
+			if changed {
+				queue = append(queue, pos)
+			}
+		}
+	}
+
+	queue = append(queue, d.constrainNeighbours(row, col)...)
+
+	if err := d.propagate(queue); err != nil {
+		return err
+	}
+	return d.check()
+}
+
+// full returns the domain of a cell without any constraints
+func (d *Domains) full() domain {
+	full := newDomain(len(d.options))
+	for option := range d.options {
+		if d.remaining[d.optionType[option]] > 0 {
+			full.set(option)
+		}
+	}
+	return full
+}
+
+// cell returns the stored domain of an empty cell, storing it first if no
+// constraint has reached the cell yet
+func (d *Domains) cell(pos cellPosition) domain {
+	if cell, ok := d.cells[pos]; ok {
+		return cell
+	}
+
+	cell := d.full()
+	for option := range d.options {
+		if cell.has(option) {
+			d.support[option]++
+		}
+	}
+	d.cells[pos] = cell
+	d.trail = append(d.trail, func() {
+		for option := range d.options {
+			if cell.has(option) {
+				d.support[option]--
+			}
+		}
+		delete(d.cells, pos)
+	})
+	return cell
+}
+
+func (d *Domains) remove(cell domain, option int) {
+	cell.clear(option)
+	d.support[option]--
+	d.trail = append(d.trail, func() {
+		cell.set(option)
+		d.support[option]++
+	})
+}
+
+func (d *Domains) neighbour(row, col int, side tile.Side) (cellPosition, bool) {
+	neighbourRow, neighbourCol, ok := d.board.Neighbour(row, col, side)
+	return cellPosition{neighbourRow, neighbourCol}, ok && d.board.Contains(neighbourRow, neighbourCol)
+}
+
+// constrainNeighbours narrows the domains of the empty cells around a placed
+// tile to the options that match it, returning the cells that changed
+func (d *Domains) constrainNeighbours(row, col int) []cellPosition {
+	var changed []cellPosition
+	for side := range tile.Side(d.board.Sides()) {
+		pos, ok := d.neighbour(row, col, side)
+		if ok && d.constrain(pos) {
+			changed = append(changed, pos)
+		}
+	}
+	return changed
+}
+
+// constrainEdges narrows the domains of the empty cells along edges that
+// require a border, returning the cells that changed
+func (d *Domains) constrainEdges() []cellPosition {
+	if !d.board.Bounded() || d.board.Sides() != int(tile.SideCount) {
+		return nil
+	}
+
+	var changed []cellPosition
+	bounds := d.board.Bounds()
+	for row := bounds.Row; row < bounds.Row+bounds.Height; row++ {
+		for col := bounds.Col; col < bounds.Col+bounds.Width; col++ {
+			onEdge := false
+			for side := range tile.SideCount {
+				_, _, ok := d.board.Neighbour(row, col, side)
+				onEdge = onEdge || (!ok && d.board.edges[side].Kind == EdgeBorder)
+			}
+			if onEdge && d.board.Contains(row, col) && d.constrain(cellPosition{row, col}) {
+				changed = append(changed, cellPosition{row, col})
+			}
+		}
+	}
+	return changed
+}
+
+// constrain narrows the domain of an empty cell to the options that fit its
+// pattern on the board, reporting whether it changed
+func (d *Domains) constrain(pos cellPosition) bool {
+	if d.board.Get(pos.row, pos.col) != nil {
+		return false
+	}
+
+	cell := d.cell(pos)
+	pattern := d.board.PatternAt(pos.row, pos.col)
+	shrunk := false
+	for option := range d.options {
+		if cell.has(option) && !d.options[option].Matches(pattern) {
+			d.remove(cell, option)
+			shrunk = true
+		}
+	}
+	return shrunk
+}
+
+// propagate narrows the neighbours of every changed cell until nothing changes
+// any more. Empty cells only constrain each other when the board must be
+// filled completely.
+func (d *Domains) propagate(queue []cellPosition) error {
+	if !d.fillBoard {
+		return nil
+	}
+
+	for len(queue) > 0 {
+		pos := queue[0]
+		queue = queue[1:]
+		changedCell := d.cells[pos]
+
+		for side := range tile.Side(d.board.Sides()) {
+			neighbourPos, ok := d.neighbour(pos.row, pos.col, side)
+			if !ok || d.board.Get(neighbourPos.row, neighbourPos.col) != nil {
+				continue
+			}
+
+			neighbour := d.cell(neighbourPos)
+			towards := side.OppositeIn(d.board.Sides())
+			shrunk := false
+			for option := range d.options {
+				if neighbour.has(option) && !d.compatible[option][towards].intersects(changedCell) {
+					d.remove(neighbour, option)
+					shrunk = true
+				}
+			}
+			if !shrunk {
+				continue
+			}
+			if neighbour.count() == 0 {
+				return fmt.Errorf("no tile left fits cell [%d][%d]", neighbourPos.row, neighbourPos.col)
+			}
+			queue = append(queue, neighbourPos)
+		}
+	}
+	return nil
+}
+
+// check looks for contradictions that propagation alone does not report
+func (d *Domains) check() error {
+	if d.fillBoard {
+		for pos, cell := range d.cells {
+			if cell.count() == 0 {
+				return fmt.Errorf("no tile left fits cell [%d][%d]", pos.row, pos.col)
+			}
+		}
+		return nil
+	}
+
+	if !d.board.Bounded() || d.emptyCells > len(d.cells) {
+		return nil // some cell is still unconstrained and takes any tile
+	}
+
+	supported := make([]bool, len(d.remaining))
+	for option := range d.options {
+		if d.support[option] > 0 {
+			supported[d.optionType[option]] = true
+		}
+	}
+	for option, typeIndex := range d.optionType {
+		if d.remaining[typeIndex] > 0 && !supported[typeIndex] {
+			return fmt.Errorf("no cell can take tile %s any more", d.options[option].String())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+func TestDomainsNarrowNextToPlacedTiles(t *testing.T) {
+	board := BoardFromString(`[    ][    ][    ]
+[    ][RCCC][    ]
+[    ][    ][CCCC]`)
+	pile := Pile{
+		tile.CreateTile("FFFF"),
+		tile.CreateTile("CCFF"),
+		tile.CreateTile("RCRC"),
+	}
+
+	domains, err := NewDomains(&board, &pile)
+	if err != nil {
+		t.Fatalf("Expected no contradiction, got: %v", err)
+	}
+
+	// FFFF has 1 orientation, CCFF has 4 and RCRC has 2
+	expected := [][]int{
+		{7, 1, 7},
+		{3, 0, 1},
+		{7, 1, 0},
+	}
+	for row := range expected {
+		for col := range expected[row] {
+			if size := domains.Size(row, col); size != expected[row][col] {
+				t.Errorf("Expected %d options at [%d][%d], got %d", expected[row][col], row, col, size)
+			}
+		}
+	}
+}
+
+func TestDomainsPropagateBetweenEmptyCells(t *testing.T) {
+	input := `[CCCC][    ][    ]`
+
+	t.Run("Board must be filled", func(t *testing.T) {
+		board := BoardFromString(input)
+		pile := Pile{tile.CreateTile("CFFF"), tile.CreateTile("FFFF")}
+
+		domains, err := NewDomains(&board, &pile)
+		if err != nil {
+			t.Fatalf("Expected no contradiction, got: %v", err)
+		}
+
+		// Only FFFC fits next to the city, so its field side limits the last cell
+		if size := domains.Size(0, 1); size != 1 {
+			t.Errorf("Expected 1 option next to the city, got %d", size)
+		}
+		if size := domains.Size(0, 2); size != 4 {
+			t.Errorf("Expected 4 options in the last cell, got %d", size)
+		}
+	})
+
+	t.Run("Cells may stay empty", func(t *testing.T) {
+		board := BoardFromString(input)
+		pile := Pile{tile.CreateTile("CFFF")}
+
+		domains, err := NewDomains(&board, &pile)
+		if err != nil {
+			t.Fatalf("Expected no contradiction, got: %v", err)
+		}
+		if size := domains.Size(0, 2); size != 4 {
+			t.Errorf("Expected the last cell to stay unconstrained, got %d options", size)
+		}
+	})
+}
+
+func TestDomainsPlaceAndUndo(t *testing.T) {
+	board := BoardFromString(`[CCCC][    ][    ]`)
+	pile := Pile{tile.CreateTile("CCFF"), tile.CreateTile("CCCC")}
+
+	domains, err := NewDomains(&board, &pile)
+	if err != nil {
+		t.Fatalf("Expected no contradiction, got: %v", err)
+	}
+	before := domains.Size(0, 2)
+
+	// With a city on its left, CCFF always turns a field to the last cell,
+	// where only CCCC is left to place
+	unrotated := tile.CreateTile("CCFF")
+	placed := unrotated.Rotate(2)
+	board.Set(0, 1, &placed)
+	mark := domains.Mark()
+	if err := domains.Place(0, 1, &placed); err == nil {
+		t.Errorf("Expected a contradiction after placing %s", placed.String())
+	}
+
+	domains.Undo(mark)
+	board.Set(0, 1, nil)
+	if size := domains.Size(0, 2); size != before {
+		t.Errorf("Expected Undo to restore %d options, got %d", before, size)
+	}
+}
+
+func TestSolverPrunesWithPropagation(t *testing.T) {
+	input := `[CCCC][    ][    ]`
+	newPile := func() Pile {
+		return Pile{tile.CreateTile("CCFF"), tile.CreateTile("CCCC")}
+	}
+
+	board := BoardFromString(input)
+	pile := newPile()
+	withoutPropagation := NewSolver(&board, &pile, SolverOptions{}).Solve()
+
+	board = BoardFromString(input)
+	pile = newPile()
+	withPropagation := NewSolver(&board, &pile, SolverOptions{Propagate: true}).Solve()
+
+	if withoutPropagation.Solved || withPropagation.Solved {
+		t.Fatalf("Expected the board to be unsolvable")
+	}
+	if withoutPropagation.Stats.Backtracks != 2 {
+		t.Errorf("Expected 2 backtracks without propagation, got %d", withoutPropagation.Stats.Backtracks)
+	}
+	if withPropagation.Stats.Placements != 0 || withPropagation.Stats.Pruned != 2 {
+		t.Errorf("Expected both placements to be pruned, got %+v", withPropagation.Stats)
+	}
+	if board.String() != input || pile.Size() != 2 {
+		t.Errorf("Expected the board and pile to be restored, got:\n%s\nwith %d tiles", board.String(), pile.Size())
+	}
+}
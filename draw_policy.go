@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+// DrawPolicy decides which tiles from the pile the solver may place next
+type DrawPolicy int
+
+const (
+	// DrawTop only places the top tile of the pile, as in Carcassonne
+	DrawTop DrawPolicy = iota
+	// DrawAny lets the solver collapse a position to any matching tile from
+	// the pile, trying one copy of every tile type in pile order
+	DrawAny
+	// DrawWeighted lets the solver place any tile, trying tile types in a
+	// random order where types with more copies left are more likely to come
+	// first
+	DrawWeighted
+)
+
+var drawPolicyNames = []string{"top", "any", "weighted"}
+
+func (p DrawPolicy) String() string {
+	if p < 0 || int(p) >= len(drawPolicyNames) {
+		panic("Unknown draw policy")
+	}
+	return drawPolicyNames[p]
+}
+
+func ParseDrawPolicy(name string) (DrawPolicy, error) {
+	for i, policyName := range drawPolicyNames {
+		if policyName == name {
+			return DrawPolicy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown draw policy %q, expected one of %v", name, drawPolicyNames)
+}
+
+// candidates returns the tiles to try, one of every type in the order they
+// should be tried. The counted pile holds the same tiles as the pile, so
+// choosing among them goes over the tile types rather than every copy.
+func (p DrawPolicy) candidates(pile *Pile, counted *CountedPile, rng *rand.Rand) []tile.Tile {
+	switch p {
+	case DrawTop:
+		return []tile.Tile{*pile.PeekTop()}
+	case DrawAny:
+		// Placing another copy of the same type would lead to exactly the
+		// same search
+		return counted.Types()
+	case DrawWeighted:
+		return weightedCandidates(counted, rng)
+	default:
+		panic("Unknown draw policy")
+	}
+}
+
+// weightedCandidates returns one tile per type, ordered by a weighted random
+// sample without replacement (Efraimidis-Spirakis), using the number of
+// copies left as the weight. Without rng the most common types come first.
+func weightedCandidates(counted *CountedPile, rng *rand.Rand) []tile.Tile {
+	type weightedTile struct {
+		tile   tile.Tile
+		copies int
+		key    float64
+	}
+
+	var types []weightedTile
+	for _, t := range counted.Types() {
+		types = append(types, weightedTile{tile: t, copies: counted.Copies(t)})
+	}
+
+	for i := range types {
+		if rng != nil {
+			types[i].key = math.Pow(rng.Float64(), 1/float64(types[i].copies))
+		} else {
+			types[i].key = float64(types[i].copies)
+		}
+	}
+	sort.SliceStable(types, func(i, j int) bool {
+		return types[i].key > types[j].key
+	})
+
+	candidates := make([]tile.Tile, len(types))
+	for i, t := range types {
+		candidates[i] = t.tile
+	}
+	return candidates
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+// EdgeKind decides what the tiles along an edge of a bounded board may show
+// on the side facing it
+type EdgeKind int
+
+const (
+	// EdgeOpen accepts any border, so features may run off the board
+	EdgeOpen EdgeKind = iota
+	// EdgeBorder requires a single border, e.g. Field to close the map
+	EdgeBorder
+	// EdgeWrap joins the edge to the opposite one, so the tiles along it
+	// must match the tiles on the other side of the board
+	EdgeWrap
+)
+
+// EdgeRule is the rule of a single board edge
+type EdgeRule struct {
+	Kind   EdgeKind
+	Border tile.Border // required border of an EdgeBorder rule
+}
+
+func (r EdgeRule) String() string {
+	switch r.Kind {
+	case EdgeOpen:
+		return "open"
+	case EdgeBorder:
+		return r.Border.String()
+	case EdgeWrap:
+		return "wrap"
+	default:
+		panic("Unknown edge kind")
+	}
+}
+
+// Edges holds the rule of every edge of a bounded board, indexed by the side
+// of the tiles that face it
+type Edges [tile.SideCount]EdgeRule
+
+func (e Edges) String() string {
+	rules := make([]string, len(e))
+	for side, rule := range e {
+		rules[side] = rule.String()
+	}
+	return strings.Join(rules, ",")
+}
+
+func (e Edges) validate() error {
+	for side := range tile.SideCount {
+		if (e[side].Kind == EdgeWrap) != (e[side.Opposite()].Kind == EdgeWrap) {
+			return fmt.Errorf("edges %s: a wrapping edge needs the opposite edge to wrap as well", e)
+		}
+	}
+	return nil
+}
+
+// ParseEdges reads either a single rule for all edges or four comma-separated
+// rules for the top, right, bottom and left edges. A rule is open, wrap or a
+// border letter such as F.
+func ParseEdges(s string) (Edges, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) == 1 {
+		parts = []string{s, s, s, s}
+	}
+	if len(parts) != int(tile.SideCount) {
+		return Edges{}, fmt.Errorf("invalid edges %q, expected 1 or %d rules", s, tile.SideCount)
+	}
+
+	var edges Edges
+	for side, part := range parts {
+		switch part {
+		case "open":
+			edges[side] = EdgeRule{Kind: EdgeOpen}
+		case "wrap":
+			edges[side] = EdgeRule{Kind: EdgeWrap}
+		default:
+			border, err := tile.ParseBorder(part)
+			if err != nil {
+				return Edges{}, fmt.Errorf("invalid edges %q: %w", s, err)
+			}
+			edges[side] = EdgeRule{Kind: EdgeBorder, Border: border}
+		}
+	}
+	return edges, edges.validate()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+func TestParseEdges(t *testing.T) {
+	edges, err := ParseEdges("wrap,F,open,F")
+	if err == nil {
+		t.Errorf("Expected an error for a top edge that wraps without the bottom one, got %v", edges)
+	}
+
+	edges, err = ParseEdges("F,wrap,C,wrap")
+	if err != nil {
+		t.Fatalf("Expected valid edges, got error: %v", err)
+	}
+	expected := Edges{
+		{Kind: EdgeBorder, Border: tile.Field},
+		{Kind: EdgeWrap},
+		{Kind: EdgeBorder, Border: tile.City},
+		{Kind: EdgeWrap},
+	}
+	if edges != expected || edges.String() != "F,wrap,C,wrap" {
+		t.Errorf("Expected %v, got %v", expected, edges)
+	}
+
+	if edges, err := ParseEdges("open"); err != nil || edges != (Edges{}) {
+		t.Errorf("Expected open edges to be the default, got %v, %v", edges, err)
+	}
+	for _, invalid := range []string{"X", "F,F", "closed"} {
+		if _, err := ParseEdges(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestEdgeRulesApplyToPatterns(t *testing.T) {
+	board := BoardFromString(`[CFFF][    ][    ]`)
+
+	fields, _ := ParseEdges("F")
+	if err := board.SetEdges(fields); err != nil {
+		t.Fatalf("Could not set edges: %v", err)
+	}
+	if pattern := board.GetTilePattern(0, 2); pattern != "FFF?" {
+		t.Errorf("Expected the corner to require fields on the edges, got %s", pattern)
+	}
+
+	wrap, _ := ParseEdges("wrap")
+	if err := board.SetEdges(wrap); err != nil {
+		t.Fatalf("Could not set edges: %v", err)
+	}
+	if pattern := board.GetTilePattern(0, 2); pattern != "?F??" {
+		t.Errorf("Expected the right side to wrap round to CFFF, got %s", pattern)
+	}
+	if !hasAdjacentTile(&board, 0, 2) {
+		t.Errorf("Expected the last cell to be next to the first one")
+	}
+}
+
+func TestSolverClosesTheMap(t *testing.T) {
+	board := BoardFromString(`[    ][FRFR][    ]`)
+	fields, _ := ParseEdges("F")
+	if err := board.SetEdges(fields); err != nil {
+		t.Fatalf("Could not set edges: %v", err)
+	}
+	pile := Pile{tile.CreateTile("RFFF"), tile.CreateTile("RFFF")}
+
+	result := NewSolver(&board, &pile, SolverOptions{Propagate: true}).Solve()
+	if !result.Solved {
+		t.Fatalf("Expected the road to be closed at both ends, got error: %v", result.Err)
+	}
+	if board.String() != "[FRFF][FRFR][FFFR]" {
+		t.Errorf("Expected roads to end before the edge, got %s", board.String())
+	}
+}
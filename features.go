@@ -0,0 +1,313 @@
+package main
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+// BoardFeature is a feature that runs across the placed tiles, such as a city
+// spread over several tiles or a road from one crossing to the next
+type BoardFeature struct {
+	// ID identifies the feature until the next tile is placed or removed,
+	// when features may be merged or split
+	ID     int
+	Border tile.Border
+	Cells  []cellPosition // cells the feature reaches, in row-major order
+
+	// Pennants counts the pennants of the tile features it joins
+	Pennants int
+
+	// Open counts the slots of the feature that face an empty cell, a
+	// blocked cell or the edge of the board
+	Open int
+}
+
+// Closed reports whether the feature has no open slots left, e.g. a city
+// surrounded by walls. A feature reaching a blocked cell or the edge of a
+// bounded board can never be closed.
+func (f *BoardFeature) Closed() bool {
+	return f.Open == 0
+}
+
+// featureTracker joins the features of the placed tiles into board features
+// with a union-find structure. There is a node for every feature of every
+// placed tile. Nodes are merged by size without path compression, so that
+// every change can be undone in reverse order, see Board.Set.
+type featureTracker struct {
+	parent   []int
+	size     []int
+	open     []int // open slots of every root
+	pennants []int // pennants of every root
+	border   []tile.Border
+	cell     []cellPosition
+
+	// members holds the nodes of every root, so that a feature can be
+	// described without going over every node
+	members [][]int
+
+	// slots holds the node of every slot of every placed tile, indexed by
+	// side and segment
+	slots map[cellPosition][]int
+
+	// placed holds the undo trail position before every placement, most
+	// recent last
+	placed []placement
+	trail  []func()
+}
+
+type placement struct {
+	pos  cellPosition
+	mark int
+}
+
+func newFeatureTracker() *featureTracker {
+	return &featureTracker{slots: make(map[cellPosition][]int)}
+}
+
+// clone copies the features for a copy of the board. The undo trail refers
+// to this tracker, so the copy starts without one and tracks its features
+// anew when one of the tiles it already holds is removed.
+func (f *featureTracker) clone() *featureTracker {
+	return &featureTracker{
+		parent:   slices.Clone(f.parent),
+		size:     slices.Clone(f.size),
+		open:     slices.Clone(f.open),
+		pennants: slices.Clone(f.pennants),
+		border:   slices.Clone(f.border),
+		cell:     slices.Clone(f.cell),
+		members:  cloneMembers(f.members),
+		// The slots of a placed tile never change
+		slots: maps.Clone(f.slots),
+	}
+}
+
+func (f *featureTracker) find(node int) int {
+	for f.parent[node] != node {
+		node = f.parent[node]
+	}
+	return node
+}
+
+func (f *featureTracker) union(a, b int) {
+	a, b = f.find(a), f.find(b)
+	if a == b {
+		return
+	}
+	if f.size[a] < f.size[b] {
+		a, b = b, a
+	}
+	f.parent[b] = a
+	f.size[a] += f.size[b]
+	f.open[a] += f.open[b]
+	f.pennants[a] += f.pennants[b]
+	members := f.members[a]
+	f.members[a] = append(f.members[a], f.members[b]...)
+	f.trail = append(f.trail, func() {
+		f.members[a] = members
+		f.parent[b] = b
+		f.size[a] -= f.size[b]
+		f.open[a] -= f.open[b]
+		f.pennants[a] -= f.pennants[b]
+	})
+}
+
+func (f *featureTracker) addOpen(node, delta int) {
+	root := f.find(node)
+	f.open[root] += delta
+	f.trail = append(f.trail, func() { f.open[root] -= delta })
+}
+
+// place adds the features of the tile the board now holds at pos, joining
+// them to the features of its neighbours
+func (f *featureTracker) place(b *Board, pos cellPosition) {
+	f.placed = append(f.placed, placement{pos: pos, mark: len(f.trail)})
+	t := b.Get(pos.row, pos.col)
+
+	nodes := len(f.parent)
+	slots := make([]int, t.Sides()*int(tile.SegmentCount))
+	for _, feature := range t.Features() {
+		node := len(f.parent)
+		f.parent = append(f.parent, node)
+		f.size = append(f.size, 1)
+		f.open = append(f.open, 0)
+		f.pennants = append(f.pennants, 0)
+		if feature.Pennant {
+			f.pennants[node] = 1
+		}
+		f.border = append(f.border, feature.Border)
+		f.cell = append(f.cell, pos)
+		f.members = append(f.members, []int{node})
+		for _, slot := range feature.Slots {
+			slots[slotIndex(slot)] = node
+		}
+	}
+	f.slots[pos] = slots
+	f.trail = append(f.trail, func() {
+		f.parent, f.size, f.open, f.pennants = f.parent[:nodes], f.size[:nodes], f.open[:nodes], f.pennants[:nodes]
+		f.border, f.cell, f.members = f.border[:nodes], f.cell[:nodes], f.members[:nodes]
+		delete(f.slots, pos)
+	})
+
+	for side := range tile.Side(t.Sides()) {
+		neighbourRow, neighbourCol, ok := b.Neighbour(pos.row, pos.col, side)
+		neighbourPos := cellPosition{neighbourRow, neighbourCol}
+		neighbourSlots, placed := f.slots[neighbourPos]
+		for segment := range tile.SegmentCount {
+			node := slots[slotIndex(tile.Slot{Side: side, Segment: segment})]
+			if !ok || !placed {
+				f.addOpen(node, 1)
+				continue
+			}
+
+			// The neighbour sees the segments of the shared side the other
+			// way round
+			other := neighbourSlots[slotIndex(tile.Slot{Side: side.OppositeIn(t.Sides()), Segment: tile.SegmentCount - 1 - segment})]
+			if neighbourPos != pos {
+				// The slot was open until now. A tile next to itself on a
+				// small torus never counted it.
+				f.addOpen(other, -1)
+			}
+			// Touching borders of different types, such as a stream flowing
+			// into a lake, close each other without being joined
+			if f.border[node] == f.border[other] {
+				f.union(node, other)
+			}
+		}
+	}
+}
+
+// untrackTile takes the tile at pos out of the features before the board
+// forgets it. Only the most recent placement can be undone; after any other
+// removal the features are tracked anew from the remaining tiles.
+func (b *Board) untrackTile(pos cellPosition) {
+	f := b.features
+	if len(f.placed) == 0 || f.placed[len(f.placed)-1].pos != pos {
+		b.features = nil
+		return
+	}
+
+	last := f.placed[len(f.placed)-1]
+	for i := len(f.trail) - 1; i >= last.mark; i-- {
+		f.trail[i]()
+	}
+	f.trail = f.trail[:last.mark]
+	f.placed = f.placed[:len(f.placed)-1]
+}
+
+// touching returns the roots of the features of a placed tile and of the
+// features of its neighbours that face it, in order
+func (f *featureTracker) touching(b *Board, pos cellPosition) []int {
+	var roots []int
+	for _, node := range f.slots[pos] {
+		roots = append(roots, f.find(node))
+	}
+	for side := range tile.Side(b.Sides()) {
+		neighbourRow, neighbourCol, ok := b.Neighbour(pos.row, pos.col, side)
+		neighbourSlots, placed := f.slots[cellPosition{neighbourRow, neighbourCol}]
+		if !ok || !placed {
+			continue
+		}
+		for segment := range tile.SegmentCount {
+			roots = append(roots, f.find(neighbourSlots[slotIndex(tile.Slot{Side: side.OppositeIn(b.Sides()), Segment: segment})]))
+		}
+	}
+	slices.Sort(roots)
+	return slices.Compact(roots)
+}
+
+// feature describes the board feature of a root node
+func (f *featureTracker) feature(root int) BoardFeature {
+	feature := BoardFeature{ID: root, Border: f.border[root], Open: f.open[root], Pennants: f.pennants[root]}
+	for _, node := range f.members[root] {
+		feature.Cells = append(feature.Cells, f.cell[node])
+	}
+	slices.SortFunc(feature.Cells, compareCells)
+	feature.Cells = slices.Compact(feature.Cells)
+	return feature
+}
+
+// cloneMembers copies the member lists, which would otherwise share the
+// arrays that later unions append to
+func cloneMembers(members [][]int) [][]int {
+	clone := make([][]int, len(members))
+	for i, nodes := range members {
+		clone[i] = slices.Clone(nodes)
+	}
+	return clone
+}
+
+func slotIndex(slot tile.Slot) int {
+	return int(slot.Side)*int(tile.SegmentCount) + int(slot.Segment)
+}
+
+func compareCells(a, b cellPosition) int {
+	if a.row != b.row {
+		return a.row - b.row
+	}
+	return a.col - b.col
+}
+
+// trackFeatures starts tracking the features of the board, beginning with
+// the tiles already placed
+func (b *Board) trackFeatures() *featureTracker {
+	if b.features != nil {
+		return b.features
+	}
+	b.features = newFeatureTracker()
+	positions := make([]cellPosition, 0, len(b.tiles))
+	for pos := range b.tiles {
+		positions = append(positions, pos)
+	}
+	slices.SortFunc(positions, compareCells)
+	for _, pos := range positions {
+		b.features.place(b, pos)
+	}
+	return b.features
+}
+
+// Place puts a tile on the board like Set and returns the features it
+// closes, e.g. a city whose last open side it walls off. Fields are never
+// reported. Once Place or Features has been called, the board keeps track of
+// its features on every change.
+func (b *Board) Place(row, col int, t *tile.Tile) []BoardFeature {
+	b.trackFeatures()
+	b.Set(row, col, t)
+	if t == nil {
+		return nil
+	}
+
+	// Only the features of the tile and those it touches can have closed
+	features := b.trackFeatures()
+	var closed []BoardFeature
+	for _, root := range features.touching(b, cellPosition{row, col}) {
+		if features.open[root] == 0 && features.border[root].Role() != tile.FieldRole {
+			closed = append(closed, features.feature(root))
+		}
+	}
+	return closed
+}
+
+// Features returns every feature of the placed tiles, ordered by ID
+func (b *Board) Features() []BoardFeature {
+	features := b.trackFeatures()
+	var all []BoardFeature
+	for node := range features.parent {
+		if features.parent[node] == node {
+			all = append(all, features.feature(node))
+		}
+	}
+	return all
+}
+
+// FeatureAt returns the board feature that a slot of a placed tile belongs
+// to
+func (b *Board) FeatureAt(row, col int, slot tile.Slot) (BoardFeature, bool) {
+	features := b.trackFeatures()
+	slots, ok := features.slots[cellPosition{row, col}]
+	if !ok {
+		return BoardFeature{}, false
+	}
+	return features.feature(features.find(slots[slotIndex(slot)])), true
+}
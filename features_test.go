@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+func TestPlaceReportsClosedFeatures(t *testing.T) {
+	board := NewUnboundedBoard()
+	top, bottom := tile.CreateTile("FRCF"), tile.CreateTile("CFFF")
+	if closed := board.Place(0, 0, &top); len(closed) != 0 {
+		t.Errorf("Expected nothing closed by the first tile, got %v", closed)
+	}
+
+	closed := board.Place(1, 0, &bottom)
+	if len(closed) != 1 || closed[0].Border != tile.City {
+		t.Fatalf("Expected the city to be closed, got %v", closed)
+	}
+	if expected := []cellPosition{{0, 0}, {1, 0}}; !slices.Equal(closed[0].Cells, expected) {
+		t.Errorf("Expected the city to cover %v, got %v", expected, closed[0].Cells)
+	}
+
+	// The road ends on the first tile and still runs off to the right
+	road, ok := board.FeatureAt(0, 0, tile.Slot{Side: tile.SideRight, Segment: tile.SegmentCentre})
+	if !ok || road.Border != tile.Road || road.Closed() || road.Open != 3 {
+		t.Errorf("Expected an open road with 3 open slots, got %v", road)
+	}
+
+	roadEnd := tile.CreateTile("FFFR")
+	closed = board.Place(0, 1, &roadEnd)
+	if len(closed) != 1 || closed[0].Border != tile.Road || len(closed[0].Cells) != 2 {
+		t.Errorf("Expected the road to be closed, got %v", closed)
+	}
+}
+
+func TestRemovingTilesReopensFeatures(t *testing.T) {
+	board := BoardFromString("[FFCF]\n[CFFF]")
+	city, _ := board.FeatureAt(0, 0, tile.Slot{Side: tile.SideBottom, Segment: tile.SegmentCentre})
+	if !city.Closed() {
+		t.Fatalf("Expected the city of the drawing to be closed, got %v", city)
+	}
+
+	board = BoardFromString("[FFCF]\n[    ]")
+	bottom := tile.CreateTile("CFFF")
+	board.Place(1, 0, &bottom)
+	board.Set(1, 0, nil)
+	city, _ = board.FeatureAt(0, 0, tile.Slot{Side: tile.SideBottom, Segment: tile.SegmentCentre})
+	if city.Closed() || len(city.Cells) != 1 || len(board.Features()) != 2 {
+		t.Errorf("Expected the city to be open again, got %v", board.Features())
+	}
+}
+
+func TestSegmentedFeaturesJoinMirrored(t *testing.T) {
+	// A road through the middle of the right side, with a city in the left
+	// third of the same side
+	left, right := tile.CreateTile("F(CRF)FF TRrBL Rl Rc"), tile.CreateTile("FFF(FRC) TRBLl Lc Lr")
+	board := NewUnboundedBoard()
+	board.Place(0, 0, &left)
+	closed := board.Place(0, 1, &right)
+
+	// The city thirds meet, and so do the roads; both end on the tiles
+	if len(closed) != 2 {
+		t.Errorf("Expected the road and the city to be closed, got %v", closed)
+	}
+	field, _ := board.FeatureAt(0, 0, tile.Slot{Side: tile.SideTop, Segment: tile.SegmentCentre})
+	if len(field.Cells) != 2 || field.Open != 18 {
+		t.Errorf("Expected the fields to be joined with 18 open slots, got %v", field)
+	}
+}
+
+func TestFeaturesFollowRemovedTiles(t *testing.T) {
+	summarize := func(features []BoardFeature) []string {
+		var summary []string
+		for _, feature := range features {
+			summary = append(summary, fmt.Sprint(feature.Border, feature.Cells, feature.Open))
+		}
+		slices.Sort(summary)
+		return summary
+	}
+
+	// A small torus, where tiles also touch themselves across the edges
+	rng := rand.New(rand.NewSource(7))
+	board := NewTorusBoard(3, 3)
+	board.Features()
+
+	var placed []cellPosition
+	for step := range 200 {
+		switch {
+		case len(placed) > 0 && rng.Intn(3) == 0:
+			// Undo the last placement, like the solver when it backtracks
+			last := placed[len(placed)-1]
+			board.Set(last.row, last.col, nil)
+			placed = placed[:len(placed)-1]
+		case len(placed) > 0 && rng.Intn(5) == 0:
+			i := rng.Intn(len(placed))
+			board.Set(placed[i].row, placed[i].col, nil)
+			placed = slices.Delete(placed, i, i+1)
+		default:
+			pos := cellPosition{rng.Intn(3), rng.Intn(3)}
+			if board.Get(pos.row, pos.col) != nil {
+				continue
+			}
+			randomTile := tile.CreateRandomTile(rng)
+			board.Place(pos.row, pos.col, &randomTile)
+			placed = append(placed, pos)
+		}
+
+		// Tracking the board anew gives the same features
+		fresh := board.Clone()
+		fresh.features = nil
+		if tracked, fresh := summarize(board.Features()), summarize(fresh.Features()); !slices.Equal(tracked, fresh) {
+			t.Fatalf("Step %d: expected the tracked features\n%v\nto match\n%v", step, tracked, fresh)
+		}
+	}
+}
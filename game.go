@@ -0,0 +1,369 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/vakrim/carcassonne-wave-collapse/scoring"
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+// MeeplesPerPlayer is the supply of every player at the start of a game
+const MeeplesPerPlayer = 7
+
+// Player is a seat at the table
+type Player struct {
+	Name    string
+	Meeples int // meeples left in the supply
+	Score   int
+}
+
+// Meeple is a follower a player has put on a feature of a placed tile
+type Meeple struct {
+	Player   int
+	Row, Col int
+
+	// Slot is a slot of the tile feature the meeple stands on, unless it
+	// stands on the monastery
+	Slot      tile.Slot
+	Monastery bool
+}
+
+// The Meeple of a Move that puts no meeple down, or one on the monastery of
+// the tile
+const (
+	NoMeeple        = -1
+	MonasteryMeeple = -2
+)
+
+// Move is a placement of the drawn tile
+type Move struct {
+	Row, Col int
+	Tile     tile.Tile // the drawn tile, turned to fit
+
+	// Meeple is the index of the feature of Tile, as returned by Features,
+	// that the player puts a meeple on, or NoMeeple or MonasteryMeeple
+	Meeple int
+}
+
+// Game is a game of Carcassonne on a board: the players take turns to place
+// the tile drawn from the top of the pile and may put a meeple on one of its
+// features. Cities, roads and monasteries score as soon as they are complete,
+// which returns their meeples; everything else scores when the pile is empty.
+type Game struct {
+	board     *Board
+	pile      *Pile
+	rules     scoring.Rules
+	players   []Player
+	current   int
+	drawn     *tile.Tile
+	meeples   []Meeple
+	discarded int
+}
+
+// NewGame starts a game on a board that already holds the start tile, and
+// draws the first tile
+func NewGame(board *Board, pile *Pile, names []string, rules scoring.Rules) (*Game, error) {
+	if len(names) == 0 {
+		return nil, errors.New("a game needs at least one player")
+	}
+	if len(board.tiles) == 0 {
+		return nil, errors.New("a game needs a start tile on the board")
+	}
+
+	g := &Game{board: board, pile: pile, rules: rules}
+	for _, name := range names {
+		g.players = append(g.players, Player{Name: name, Meeples: MeeplesPerPlayer})
+	}
+	board.trackFeatures()
+	g.draw()
+	return g, nil
+}
+
+func (g *Game) Board() *Board {
+	return g.board
+}
+
+// Players returns the players in turn order, with their supplies and scores
+func (g *Game) Players() []Player {
+	return slices.Clone(g.players)
+}
+
+// Current returns the index of the player whose turn it is
+func (g *Game) Current() int {
+	return g.current
+}
+
+// Drawn returns the tile the current player has to place, or nil once the
+// game is over
+func (g *Game) Drawn() *tile.Tile {
+	return g.drawn
+}
+
+// Meeples returns the meeples on the board
+func (g *Game) Meeples() []Meeple {
+	return slices.Clone(g.meeples)
+}
+
+// Discarded counts the drawn tiles that could not be placed anywhere
+func (g *Game) Discarded() int {
+	return g.discarded
+}
+
+func (g *Game) Over() bool {
+	return g.drawn == nil
+}
+
+// draw takes the next tile from the pile. As in Carcassonne, tiles that fit
+// nowhere are discarded. When the pile runs out the game ends and the
+// remaining features are scored.
+func (g *Game) draw() {
+	for g.pile.hasMoreTiles() {
+		drawn := g.pile.PopTop()
+		g.drawn = &drawn
+		if len(g.placements()) > 0 {
+			return
+		}
+		g.discarded++
+	}
+	g.drawn = nil
+	g.finish()
+}
+
+// placements returns every position and orientation of the drawn tile,
+// without meeples
+func (g *Game) placements() []Move {
+	var moves []Move
+	area := g.board.Area()
+	for row := area.Row; row < area.Row+area.Height; row++ {
+		for col := area.Col; col < area.Col+area.Width; col++ {
+			if g.board.Get(row, col) != nil || !hasAdjacentTile(g.board, row, col) {
+				continue
+			}
+			pattern := g.board.PatternAt(row, col)
+			for _, rotated := range g.drawn.DistinctRotations() {
+				if rotated.Matches(pattern) {
+					moves = append(moves, Move{Row: row, Col: col, Tile: rotated, Meeple: NoMeeple})
+				}
+			}
+		}
+	}
+	return moves
+}
+
+// LegalMoves returns every move the current player may make with the drawn
+// tile: every position and orientation it fits, each without a meeple and,
+// while the player has meeples left, with a meeple on any of its features
+// that does not join a feature some meeple already stands on, or on its
+// monastery
+func (g *Game) LegalMoves() []Move {
+	if g.Over() {
+		return nil
+	}
+
+	var moves []Move
+	for _, placement := range g.placements() {
+		moves = append(moves, placement)
+		if g.players[g.current].Meeples > 0 {
+			moves = append(moves, g.meepleMoves(placement)...)
+		}
+	}
+	return moves
+}
+
+// meepleMoves returns the placement with a meeple on every free feature of
+// the tile, trying the tile on the board to see which features it joins
+func (g *Game) meepleMoves(placement Move) []Move {
+	placed := placement.Tile
+	mark := g.board.Mark()
+	g.board.Set(placement.Row, placement.Col, &placed)
+	defer g.board.Undo(mark)
+
+	features := g.board.trackFeatures()
+	var moves []Move
+	for i, feature := range placed.Features() {
+		root := features.find(features.slots[cellPosition{placement.Row, placement.Col}][slotIndex(feature.Slots[0])])
+		if !g.occupied(root) {
+			moves = append(moves, Move{Row: placement.Row, Col: placement.Col, Tile: placed, Meeple: i})
+		}
+	}
+	if placed.Monastery() {
+		moves = append(moves, Move{Row: placement.Row, Col: placement.Col, Tile: placed, Meeple: MonasteryMeeple})
+	}
+	return moves
+}
+
+// root returns the root of the board feature a meeple stands on
+func (g *Game) root(meeple Meeple) int {
+	features := g.board.trackFeatures()
+	return features.find(features.slots[cellPosition{meeple.Row, meeple.Col}][slotIndex(meeple.Slot)])
+}
+
+func (g *Game) occupied(root int) bool {
+	for _, meeple := range g.meeples {
+		if !meeple.Monastery && g.root(meeple) == root {
+			return true
+		}
+	}
+	return false
+}
+
+// Play makes a move of LegalMoves for the current player, scores what it
+// completes and passes the turn on
+func (g *Game) Play(move Move) error {
+	if g.Over() {
+		return errors.New("the game is over")
+	}
+	if !slices.Contains(g.LegalMoves(), move) {
+		return fmt.Errorf("%s cannot be placed at [%d][%d] like this", move.Tile.Describe(), move.Row, move.Col)
+	}
+	g.play(move)
+	return nil
+}
+
+// play makes a move that is known to be legal
+func (g *Game) play(move Move) {
+	placed := move.Tile
+	closed := g.board.Place(move.Row, move.Col, &placed)
+	switch move.Meeple {
+	case NoMeeple:
+	case MonasteryMeeple:
+		g.meeples = append(g.meeples, Meeple{Player: g.current, Row: move.Row, Col: move.Col, Monastery: true})
+		g.players[g.current].Meeples--
+	default:
+		slot := placed.Features()[move.Meeple].Slots[0]
+		g.meeples = append(g.meeples, Meeple{Player: g.current, Row: move.Row, Col: move.Col, Slot: slot})
+		g.players[g.current].Meeples--
+	}
+
+	g.scoreClosed(closed)
+	g.scoreMonasteries()
+	g.current = (g.current + 1) % len(g.players)
+	g.draw()
+}
+
+// scoreClosed scores the features a placement closed and returns their
+// meeples to their players
+func (g *Game) scoreClosed(closed []BoardFeature) {
+	for _, feature := range closed {
+		var players []int
+		g.meeples = slices.DeleteFunc(g.meeples, func(meeple Meeple) bool {
+			if meeple.Monastery || g.root(meeple) != feature.ID {
+				return false
+			}
+			players = append(players, meeple.Player)
+			g.players[meeple.Player].Meeples++
+			return true
+		})
+		if len(players) == 0 {
+			continue
+		}
+
+		g.award(scoring.Feature{
+			Kind:     scoringKind(feature.Border),
+			Tiles:    len(feature.Cells),
+			Pennants: feature.Pennants,
+			Complete: true,
+			Meeples:  players,
+		})
+	}
+}
+
+// scoreMonasteries scores the monasteries with meeples that are surrounded
+// by tiles
+func (g *Game) scoreMonasteries() {
+	g.meeples = slices.DeleteFunc(g.meeples, func(meeple Meeple) bool {
+		if !meeple.Monastery {
+			return false
+		}
+		tiles, complete := g.board.neighbourhood(meeple.Row, meeple.Col)
+		if !complete {
+			return false
+		}
+		g.award(scoring.Feature{Kind: scoring.Monastery, Tiles: tiles, Complete: true, Meeples: []int{meeple.Player}})
+		g.players[meeple.Player].Meeples++
+		return true
+	})
+}
+
+func (g *Game) award(feature scoring.Feature) {
+	for player, points := range g.rules.Score([]scoring.Feature{feature}).Players {
+		g.players[player].Score += points
+	}
+}
+
+// projectedScores returns the scores of the players if the game ended now
+func (g *Game) projectedScores() []int {
+	scores := make([]int, len(g.players))
+	for i, player := range g.players {
+		scores[i] = player.Score
+	}
+	if !g.Over() {
+		for player, points := range g.rules.Score(g.board.scoringFeatures(g.meeples)).Players {
+			scores[player] += points
+		}
+	}
+	return scores
+}
+
+// clone returns a copy of the game that can be played on without changing
+// this one
+func (g *Game) clone() *Game {
+	clone := *g
+	clone.board = g.board.Clone()
+	pile := g.pile.Clone()
+	clone.pile = &pile
+	clone.players = slices.Clone(g.players)
+	clone.meeples = slices.Clone(g.meeples)
+	if g.drawn != nil {
+		drawn := *g.drawn
+		clone.drawn = &drawn
+	}
+	return &clone
+}
+
+// gameMark is the state of a game at some turn, to go back to with undo. The
+// board goes back through its own undo trail.
+type gameMark struct {
+	board     int
+	pile      Pile
+	players   []Player
+	current   int
+	drawn     *tile.Tile
+	meeples   []Meeple
+	discarded int
+}
+
+// mark records the state of the game, which is much cheaper than a clone as
+// the board is not copied
+func (g *Game) mark() gameMark {
+	return gameMark{
+		board:     g.board.Mark(),
+		pile:      g.pile.Clone(),
+		players:   slices.Clone(g.players),
+		current:   g.current,
+		drawn:     g.drawn,
+		meeples:   slices.Clone(g.meeples),
+		discarded: g.discarded,
+	}
+}
+
+// undo takes the game back to a mark, undoing every move since
+func (g *Game) undo(mark gameMark) {
+	g.board.Undo(mark.board)
+	*g.pile = slices.Clone(mark.pile)
+	g.players = slices.Clone(mark.players)
+	g.current = mark.current
+	g.drawn = mark.drawn
+	g.meeples = slices.Clone(mark.meeples)
+	g.discarded = mark.discarded
+}
+
+// finish scores the features that still have meeples on them, fields
+// included, at the end of the game
+func (g *Game) finish() {
+	for player, points := range g.rules.Score(g.board.scoringFeatures(g.meeples)).Players {
+		g.players[player].Score += points
+	}
+}
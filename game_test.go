@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/vakrim/carcassonne-wave-collapse/scoring"
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+// featureIndex returns the index of the first feature of the tile with the
+// given border
+func featureIndex(t tile.Tile, border tile.Border) int {
+	for i, feature := range t.Features() {
+		if feature.Border == border {
+			return i
+		}
+	}
+	return NoMeeple
+}
+
+func TestLegalMoves(t *testing.T) {
+	board := BoardFromString("[    ][    ][    ]\n[    ][CFFF][    ]\n[    ][    ][    ]")
+	pile := Pile{tile.CreateTile("FFCF")}
+	game, err := NewGame(&board, &pile, []string{"Ann", "Bob"}, scoring.DefaultRules)
+	if err != nil {
+		t.Fatalf("Expected the game to start, got error: %v", err)
+	}
+
+	// One orientation fits above the start tile and three on every other
+	// side, each without a meeple or with one on the field or the city
+	if moves := game.LegalMoves(); len(moves) != 30 {
+		t.Errorf("Expected 30 legal moves, got %d", len(moves))
+	}
+	if err := game.Play(Move{Row: 0, Col: 0, Tile: *game.Drawn(), Meeple: NoMeeple}); err == nil {
+		t.Errorf("Expected a tile away from the others to be rejected")
+	}
+}
+
+func TestCompletedCityScores(t *testing.T) {
+	board := BoardFromString("[    ]\n[CFFF]")
+	pile := Pile{tile.CreateTile("FFCF")}
+	game, err := NewGame(&board, &pile, []string{"Ann", "Bob"}, scoring.DefaultRules)
+	if err != nil {
+		t.Fatalf("Expected the game to start, got error: %v", err)
+	}
+
+	drawn := *game.Drawn()
+	if err := game.Play(Move{Row: 0, Col: 0, Tile: drawn, Meeple: featureIndex(drawn, tile.City)}); err != nil {
+		t.Fatalf("Expected the move to be legal, got error: %v", err)
+	}
+
+	players := game.Players()
+	if players[0].Score != 4 || players[0].Meeples != MeeplesPerPlayer {
+		t.Errorf("Expected 4 points and the meeple back, got %+v", players[0])
+	}
+	if !game.Over() || game.Current() != 1 {
+		t.Errorf("Expected the game to be over after the only tile")
+	}
+}
+
+func TestMeeplesCannotJoinOccupiedFeatures(t *testing.T) {
+	board := UnboundedBoardFromString("[FRFR]")
+	pile := Pile{tile.CreateTile("FRFR"), tile.CreateTile("FRFR")}
+	game, err := NewGame(&board, &pile, []string{"Ann", "Bob"}, scoring.DefaultRules)
+	if err != nil {
+		t.Fatalf("Expected the game to start, got error: %v", err)
+	}
+
+	drawn := *game.Drawn()
+	if err := game.Play(Move{Row: 0, Col: 1, Tile: drawn, Meeple: featureIndex(drawn, tile.Road)}); err != nil {
+		t.Fatalf("Expected the move to be legal, got error: %v", err)
+	}
+	for _, move := range game.LegalMoves() {
+		if move.Row == 0 && move.Col == 2 && move.Meeple == featureIndex(move.Tile, tile.Road) {
+			t.Errorf("Expected no meeple on the road Ann already holds, got %+v", move)
+		}
+	}
+
+	// The unfinished road scores a point per tile at the end
+	drawn = *game.Drawn()
+	if err := game.Play(Move{Row: 0, Col: 2, Tile: drawn, Meeple: NoMeeple}); err != nil {
+		t.Fatalf("Expected the move to be legal, got error: %v", err)
+	}
+	if players := game.Players(); !game.Over() || players[0].Score != 3 || players[1].Score != 0 {
+		t.Errorf("Expected 3 points for Ann at the end, got %+v", players)
+	}
+}
+
+func TestTilesThatFitNowhereAreDiscarded(t *testing.T) {
+	board := BoardFromString("[CCCC][    ]")
+	pile := Pile{tile.CreateTile("FFFF"), tile.CreateTile("CCCC")}
+	game, err := NewGame(&board, &pile, []string{"Ann"}, scoring.DefaultRules)
+	if err != nil {
+		t.Fatalf("Expected the game to start, got error: %v", err)
+	}
+	if game.Discarded() != 1 || game.Drawn().String() != "CCCC" {
+		t.Errorf("Expected FFFF to be discarded, drew %s", game.Drawn().String())
+	}
+}
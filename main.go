@@ -3,50 +3,16 @@ package main
 import (
 	"bufio"
 	"fmt"
-	"log"
 	"os"
-	"sort"
 	"strings"
-	"time"
 
-	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/vakrim/carcassonne-wave-collapse/tile"
 )
 
 func main() {
-	pile, err := loadTilesFromFile("tiles.txt")
-	if err != nil {
-		log.Fatalf("Error loading tiles: %v", err)
-	}
-
-	const boardSize = 12
-
-	board := Board{
-		tiles: make([][]*tile.Tile, boardSize),
-	}
-
-	for i := range board.tiles {
-		board.tiles[i] = make([]*tile.Tile, boardSize)
-	}
-
-	board.tiles[6][6] = pile.PopTop()
-
-	fmt.Printf("Loaded %d tiles from file\n", len(pile))
-	fmt.Println("Starting visualization...")
-
-	solver := NewVisualizationSolver(&board, &pile)
-
-	// Start solving in background after a brief delay
-	go func() {
-		time.Sleep(time.Second * 1) // Wait before starting
-		solver.StartSolving()
-	}()
-
-	ebiten.SetWindowSize(screenWidth, screenHeight)
-	ebiten.SetWindowTitle("Carcassonne Wave Collapse Visualization")
-
-	if err := ebiten.RunGame(solver); err != nil {
-		log.Fatal(err)
+	if err := runCLI(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 }
 
@@ -60,12 +26,18 @@ func loadTilesFromFile(filename string) (Pile, error) {
 	var pile Pile
 	scanner := bufio.NewScanner(file)
 
+	lineNumber := 0
 	for scanner.Scan() {
+		lineNumber++
 		line := strings.TrimSpace(scanner.Text())
-		if line != "" && len(line) == 4 {
-			t := tile.CreateTile(line)
-			pile = append(pile, t)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue // skip blank lines and comments
+		}
+		t, err := tile.ParseTile(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", filename, lineNumber, err)
 		}
+		pile = append(pile, t)
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -75,48 +47,37 @@ func loadTilesFromFile(filename string) (Pile, error) {
 	return pile, nil
 }
 
-type PositionWithPossibilities struct {
-	row, col      int
-	possibilities int
-}
-
-func getSortedAvailablePositions(board *Board, pile *Pile) []PositionWithPossibilities {
-	possibilities := board.CountPossibilities(pile)
-	var positions []PositionWithPossibilities
-
-	// Collect all valid positions
-	for i := range possibilities {
-		for j := range possibilities[i] {
-			if !possibilities[i][j].alreadyPlaced &&
-				possibilities[i][j].possibilities > 0 &&
-				hasAdjacentTile(board, i, j) {
-				positions = append(positions, PositionWithPossibilities{
-					row:           i,
-					col:           j,
-					possibilities: possibilities[i][j].possibilities,
-				})
-			}
-		}
+// loadBorderTypesFromFile reads border types in the format of
+// tile.ParseBorderTypes and registers them instead of the default ones
+func loadBorderTypesFromFile(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
 	}
+	defer file.Close()
 
-	// Sort by possibilities (ascending - least possibilities first)
-	sort.Slice(positions, func(i, j int) bool {
-		return positions[i].possibilities < positions[j].possibilities
-	})
-
-	return positions
+	types, err := tile.ParseBorderTypes(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	if err := tile.SetBorderTypes(types); err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	return nil
 }
 
-func hasAdjacentTile(board *Board, row, col int) bool {
-	directions := [][]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} // up, down, left, right
+// loadBoardFromFile reads a partially filled board in the format of
+// Board.String. Its tiles are pinned.
+func loadBoardFromFile(filename string, unbounded bool) (Board, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return Board{}, err
+	}
 
-	for _, dir := range directions {
-		newRow, newCol := row+dir[0], col+dir[1]
-		if newRow >= 0 && newRow < len(board.tiles) &&
-			newCol >= 0 && newCol < len(board.tiles[0]) &&
-			board.tiles[newRow][newCol] != nil {
-			return true
-		}
+	drawing := strings.ReplaceAll(string(content), "\r\n", "\n")
+	board, err := ParsePinnedBoard(strings.TrimRight(drawing, "\n"), unbounded)
+	if err != nil {
+		return Board{}, fmt.Errorf("%s: %w", filename, err)
 	}
-	return false
+	return board, nil
 }
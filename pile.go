@@ -1,6 +1,10 @@
 package main
 
 import (
+	"fmt"
+	"math/rand"
+	"slices"
+
 	"github.com/vakrim/carcassonne-wave-collapse/tile"
 )
 
@@ -21,48 +25,134 @@ func (p *Pile) PeekTop() *tile.Tile {
 	return &(*p)[0]
 }
 
-func (p *Pile) PopTop() *tile.Tile {
+// PopTop takes the top tile off the pile
+func (p *Pile) PopTop() tile.Tile {
 	if !p.hasMoreTiles() {
 		panic("No more tiles in the pile")
 	}
 	tile := (*p)[0]
 	*p = (*p)[1:]
-	return &tile
+	return tile
 }
 
 func (p *Pile) PushTop(t *tile.Tile) {
 	*p = append([]tile.Tile{*t}, *p...)
 }
 
+// RemoveAt takes the tile at the given index out of the pile
+func (p *Pile) RemoveAt(index int) tile.Tile {
+	t := (*p)[index]
+	*p = append((*p)[:index], (*p)[index+1:]...)
+	return t
+}
+
+// indexOf returns the index of the first tile of the pile that is a rotation
+// of t, or -1
+func (p *Pile) indexOf(t *tile.Tile) int {
+	rotations := t.Rotations()
+	return slices.IndexFunc(*p, func(other tile.Tile) bool {
+		return slices.ContainsFunc(rotations, func(rotated tile.Tile) bool { return rotated.Code() == other.Code() })
+	})
+}
+
+// InsertAt puts a tile back into the pile at the given index, undoing RemoveAt
+func (p *Pile) InsertAt(index int, t tile.Tile) {
+	*p = append(*p, tile.Tile{})
+	copy((*p)[index+1:], (*p)[index:])
+	(*p)[index] = t
+}
+
+// Clone returns a copy of the pile that can be changed without changing this
+// one
+func (p *Pile) Clone() Pile {
+	return slices.Clone(*p)
+}
+
+// Shuffle puts the pile in a random order determined by rng
+func (p *Pile) Shuffle(rng *rand.Rand) {
+	rng.Shuffle(len(*p), func(i, j int) {
+		(*p)[i], (*p)[j] = (*p)[j], (*p)[i]
+	})
+}
+
+// FindMatchingTile returns the first tile that fits a query as it is, or nil
+// when none does or the query is malformed
 func (p *Pile) FindMatchingTile(query string) *tile.Tile {
+	pattern, err := tile.ParsePattern(query)
+	if err != nil {
+		return nil
+	}
 	for _, t := range *p {
-		if t.MatchesQuery(query) {
+		if t.Matches(pattern) {
 			return &t
 		}
 	}
 	return nil
 }
 
+// Filter returns the tiles that fit a query in any rotation, none when the
+// query is malformed
 func (p *Pile) Filter(query string) Pile {
 	var result Pile
+	pattern, err := tile.ParsePattern(query)
+	if err != nil {
+		return result
+	}
 	for _, t := range *p {
-		if t.MatchesQuery(query) {
+		if t.MatchesInAnyRotation(pattern) {
 			result = append(result, t)
 		}
 	}
 	return result
 }
 
+// CountMatchingTiles counts the tiles that fit a query in any rotation, 0
+// when the query is malformed
 func (p *Pile) CountMatchingTiles(query string) int {
+	pattern, err := tile.ParsePattern(query)
+	if err != nil {
+		return 0
+	}
+	return p.CountMatching(pattern)
+}
+
+// CountMatching counts the tiles that fit a packed pattern in any rotation
+func (p *Pile) CountMatching(pattern tile.Pattern) int {
 	count := 0
 	for _, t := range *p {
-		if t.MatchesQuery(query) {
+		if t.MatchesInAnyRotation(pattern) {
 			count++
 		}
 	}
 	return count
 }
 
+// RemovePinnedTiles takes a copy of every pinned tile of the board out of the
+// pile, in any rotation
+func (p *Pile) RemovePinnedTiles(board *Board) error {
+	for _, pinned := range board.PinnedTiles() {
+		index := slices.IndexFunc(*p, func(t tile.Tile) bool {
+			return t.TypeCode() == pinned.TypeCode()
+		})
+		if index < 0 {
+			return fmt.Errorf("pinned tile %s is not in the pile", pinned.String())
+		}
+		p.RemoveAt(index)
+	}
+	return nil
+}
+
+// CheckSides returns an error if a tile of the pile does not have the given
+// number of sides, e.g. a square tile for a hex board
+func (p *Pile) CheckSides(sides int) error {
+	for _, t := range *p {
+		if t.Sides() != sides {
+			return fmt.Errorf("tile %s has %d sides, the board needs %d", t.String(), t.Sides(), sides)
+		}
+	}
+	return nil
+}
+
 func (p *Pile) RemoveTile(tileToRemove *tile.Tile) {
 	for i, t := range *p {
 		if t == *tileToRemove {
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"math/rand"
+	"reflect"
 	"testing"
 
 	"github.com/vakrim/carcassonne-wave-collapse/tile"
@@ -22,9 +24,10 @@ func TestFindMatchingTile(t *testing.T) {
 		t.Errorf("Expected to find a tile matching %s, but found none or incorrect tile", query)
 	}
 
-	query = "C??C"
-	if pile.FindMatchingTile(query) != nil {
-		t.Errorf("Expected to find no tile matching %s, but found one", query)
+	for _, query := range []string{"C??C", "C??X", "C?"} {
+		if pile.FindMatchingTile(query) != nil {
+			t.Errorf("Expected to find no tile matching %s, but found one", query)
+		}
 	}
 }
 
@@ -68,3 +71,127 @@ func TestRemoveTile(t *testing.T) {
 	}()
 	pile.RemoveTile(tileToRemove)
 }
+
+func TestCountMatchingTilesWithRotation(t *testing.T) {
+	pile := Pile{
+		tile.CreateTile("FFFF"),
+		tile.CreateTile("CCFF"),
+		tile.CreateTile("RCRC"),
+	}
+
+	tests := map[string]int{
+		"????": 3,
+		"FCCF": 1,
+		"??R?": 1,
+		"C?R?": 0,
+		"F???": 2,
+		"X???": 0, // malformed queries match nothing
+		"CC":   0,
+	}
+
+	for query, expected := range tests {
+		if count := pile.CountMatchingTiles(query); count != expected {
+			t.Errorf("Expected %d tiles matching %s, got %d", expected, query, count)
+		}
+	}
+
+	if filtered := pile.Filter("FCCF"); len(filtered) != 1 || filtered[0].String() != "CCFF" {
+		t.Errorf("Expected Filter to return the unrotated 'CCFF' tile, got %v", filtered)
+	}
+	if filtered := pile.Filter("FC(CF"); len(filtered) != 0 {
+		t.Errorf("Expected Filter to return no tiles for a malformed query, got %v", filtered)
+	}
+}
+
+func TestShuffleIsDeterministic(t *testing.T) {
+	newPile := func() Pile {
+		return Pile{
+			tile.CreateTile("FFFF"),
+			tile.CreateTile("CCFF"),
+			tile.CreateTile("RCRC"),
+			tile.CreateTile("SSSS"),
+			tile.CreateTile("RFRF"),
+		}
+	}
+
+	first, second := newPile(), newPile()
+	first.Shuffle(rand.New(rand.NewSource(7)))
+	second.Shuffle(rand.New(rand.NewSource(7)))
+
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected the same order for the same seed, got %v and %v", first, second)
+	}
+	if first.Size() != 5 {
+		t.Errorf("Expected shuffling to keep all 5 tiles, got %d", first.Size())
+	}
+}
+
+func TestRemoveAtAndInsertAt(t *testing.T) {
+	pile := Pile{
+		tile.CreateTile("FFFF"),
+		tile.CreateTile("CCFF"),
+		tile.CreateTile("RCRC"),
+	}
+
+	removed := pile.RemoveAt(1)
+	if removed.String() != "CCFF" || pile.Size() != 2 {
+		t.Fatalf("Expected to remove CCFF leaving 2 tiles, got %s leaving %d", removed.String(), pile.Size())
+	}
+
+	pile.InsertAt(1, removed)
+	expected := []string{"FFFF", "CCFF", "RCRC"}
+	for i, pattern := range expected {
+		if pile[i].String() != pattern {
+			t.Errorf("Expected %s at index %d after InsertAt, got %s", pattern, i, pile[i].String())
+		}
+	}
+}
+
+func TestRemovePinnedTiles(t *testing.T) {
+	board, err := ParsePinnedBoard("[FCCF][RFRF]", false)
+	if err != nil {
+		t.Fatalf("Expected the board to parse, got error: %v", err)
+	}
+	pile := Pile{
+		tile.CreateTile("FRFR"),
+		tile.CreateTile("CCFF"),
+		tile.CreateTile("CCFF"),
+	}
+
+	if err := pile.RemovePinnedTiles(&board); err != nil {
+		t.Fatalf("Expected the pinned tiles to be found in any rotation, got error: %v", err)
+	}
+	if pile.Size() != 1 || pile[0].String() != "CCFF" {
+		t.Errorf("Expected a single CCFF to be left, got %v", pile)
+	}
+
+	if err := pile.RemovePinnedTiles(&board); err == nil {
+		t.Errorf("Expected an error once RFRF is no longer in the pile")
+	}
+}
+
+func TestRemovePinnedTilesWithLayouts(t *testing.T) {
+	board, err := ParsePinnedBoard("[FCCF RB]", false)
+	if err != nil {
+		t.Fatalf("Expected the board to parse, got error: %v", err)
+	}
+	pile := Pile{tile.CreateTile("CCFF TR")}
+
+	if err := pile.RemovePinnedTiles(&board); err != nil {
+		t.Fatalf("Expected the pinned tile to be found turned, got error: %v", err)
+	}
+	if pile.Size() != 0 {
+		t.Errorf("Expected the pile to be empty, got %v", pile)
+	}
+}
+
+func TestPileClone(t *testing.T) {
+	pile := Pile{tile.CreateTile("FRFR"), tile.CreateTile("CCFF"), tile.CreateTile("CFFF")}
+	clone := pile.Clone()
+	clone.RemoveAt(1)
+	clone.Shuffle(rand.New(rand.NewSource(1)))
+
+	if pile.Size() != 3 || pile[1].String() != "CCFF" || pile[2].String() != "CFFF" {
+		t.Errorf("Expected the pile to be unchanged, got %v", pile)
+	}
+}
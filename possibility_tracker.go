@@ -0,0 +1,287 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+// PossibilityTracker keeps the possibility counts of a board up to date as
+// tiles are placed and removed, instead of recomputing every cell against the
+// whole pile like Board.CountPossibilities.
+//
+// Every tile type in the pile is indexed by its border signature: the set of
+// patterns (its borders with any combination of ? wildcards) that it matches
+// in some rotation. The number of pile tiles matching a pattern is then a
+// single lookup, and a placement only changes the patterns of the placed cell
+// and its neighbours.
+//
+// A side that can touch several borders matches many more patterns, and the
+// signatures would grow exponentially with the borders touched. Unless every
+// border only touches itself, the tile types are matched against the pattern
+// of each cell instead, remembering the types that fit until the cell
+// changes.
+type PossibilityTracker struct {
+	board *Board
+	exact bool // every border only touches itself, so signatures are used
+
+	patternCounts  map[tile.Pattern]int       // pile tiles matching each pattern
+	patternOptions map[tile.Pattern]int       // orientations of the tile types left matching each pattern
+	signatures     map[tile.Code][]patternFit // patterns matched by each tile type, by type code
+
+	types  map[tile.Code]tile.Tile    // a tile of each type, by type code
+	copies map[tile.Code]int          // pile tiles of each type
+	fits   map[cellPosition][]typeFit // types that fit each counted cell
+
+	frontier map[cellPosition]tile.Pattern // pattern of each empty cell next to a placed tile
+}
+
+// patternFit is a pattern of a signature, with the number of distinct
+// rotations of the tile type that match it
+type patternFit struct {
+	pattern   tile.Pattern
+	rotations int
+}
+
+// typeFit is a tile type that fits a cell, with the number of its distinct
+// rotations that do
+type typeFit struct {
+	key       tile.Code
+	rotations int
+}
+
+func NewPossibilityTracker(board *Board, pile *Pile) *PossibilityTracker {
+	pt := &PossibilityTracker{
+		board:          board,
+		exact:          tile.ExactTouching(),
+		patternCounts:  make(map[tile.Pattern]int),
+		patternOptions: make(map[tile.Pattern]int),
+		signatures:     make(map[tile.Code][]patternFit),
+		types:          make(map[tile.Code]tile.Tile),
+		copies:         make(map[tile.Code]int),
+		fits:           make(map[cellPosition][]typeFit),
+		frontier:       make(map[cellPosition]tile.Pattern),
+	}
+
+	counted := NewCountedPile(pile)
+	for _, t := range counted.Types() {
+		pt.add(t, counted.Copies(t))
+	}
+
+	for pos := range board.tiles {
+		pt.CellChanged(pos.row, pos.col)
+	}
+	return pt
+}
+
+// TakeTile records that t has been taken out of the pile
+func (pt *PossibilityTracker) TakeTile(t tile.Tile) {
+	pt.add(t, -1)
+}
+
+// ReturnTile records that t has been put back into the pile
+func (pt *PossibilityTracker) ReturnTile(t tile.Tile) {
+	pt.add(t, 1)
+}
+
+// add changes the number of pile tiles of the type of t
+func (pt *PossibilityTracker) add(t tile.Tile, copies int) {
+	key := t.TypeCode()
+	left := pt.copies[key] > 0
+	pt.copies[key] += copies
+
+	if pt.exact {
+		// The orientations of a type only count while copies of it are left
+		options := 0
+		if pt.copies[key] > 0 && !left {
+			options = 1
+		} else if pt.copies[key] == 0 && left {
+			options = -1
+		}
+		for _, fit := range pt.signature(t) {
+			pt.patternCounts[fit.pattern] += copies
+			pt.patternOptions[fit.pattern] += options * fit.rotations
+		}
+		return
+	}
+
+	if _, ok := pt.types[key]; !ok {
+		// A new type may fit cells that were already counted
+		pt.types[key] = t
+		clear(pt.fits)
+	}
+}
+
+// CellChanged records that a tile has been placed on or removed from the
+// given cell
+func (pt *PossibilityTracker) CellChanged(row, col int) {
+	pt.updateCell(row, col)
+	for side := range tile.Side(pt.board.Sides()) {
+		if neighbourRow, neighbourCol, ok := pt.board.Neighbour(row, col, side); ok {
+			pt.updateCell(neighbourRow, neighbourCol)
+		}
+	}
+}
+
+// Pattern returns the pattern of an empty cell, as Board.PatternAt would.
+// Only the patterns of cells next to placed tiles are kept, the others only
+// depend on the board edges.
+func (pt *PossibilityTracker) Pattern(row, col int) tile.Pattern {
+	if pattern, ok := pt.frontier[cellPosition{row, col}]; ok {
+		return pattern
+	}
+	return pt.board.PatternAt(row, col)
+}
+
+// Count returns how many pile tiles fit an empty cell in some rotation
+func (pt *PossibilityTracker) Count(row, col int) int {
+	if pt.board.Get(row, col) != nil || !pt.board.Contains(row, col) {
+		return 0
+	}
+	if pt.exact {
+		return pt.patternCounts[pt.Pattern(row, col)]
+	}
+
+	count := 0
+	for _, fit := range pt.fitting(row, col) {
+		count += pt.copies[fit.key]
+	}
+	return count
+}
+
+// Options returns how many distinct orientations of the tile types left in
+// the pile fit an empty cell, however many copies of each are left
+func (pt *PossibilityTracker) Options(row, col int) int {
+	if pt.board.Get(row, col) != nil || !pt.board.Contains(row, col) {
+		return 0
+	}
+	if pt.exact {
+		return pt.patternOptions[pt.Pattern(row, col)]
+	}
+
+	options := 0
+	for _, fit := range pt.fitting(row, col) {
+		if pt.copies[fit.key] > 0 {
+			options += fit.rotations
+		}
+	}
+	return options
+}
+
+// fitting returns the tile types that fit an empty cell in some rotation
+func (pt *PossibilityTracker) fitting(row, col int) []typeFit {
+	pos := cellPosition{row, col}
+	if fits, ok := pt.fits[pos]; ok {
+		return fits
+	}
+
+	pattern := pt.Pattern(row, col)
+	fits := []typeFit{}
+	for key, t := range pt.types {
+		if rotations := matchingRotations(t, pattern); rotations > 0 {
+			fits = append(fits, typeFit{key, rotations})
+		}
+	}
+	pt.fits[pos] = fits
+	return fits
+}
+
+// matchingRotations counts the distinct rotations of t that fit a pattern
+func matchingRotations(t tile.Tile, pattern tile.Pattern) int {
+	rotations := 0
+	for _, rotated := range t.DistinctRotations() {
+		if rotated.Matches(pattern) {
+			rotations++
+		}
+	}
+	return rotations
+}
+
+// Possibilities returns the same counts as Board.CountPossibilities
+func (pt *PossibilityTracker) Possibilities() [][]PossibilitiesCount {
+	return pt.PossibilitiesIn(pt.board.Bounds())
+}
+
+// PossibilitiesIn returns the same counts as Board.CountPossibilitiesIn
+func (pt *PossibilityTracker) PossibilitiesIn(area Rect) [][]PossibilitiesCount {
+	possibilities := make([][]PossibilitiesCount, area.Height)
+	for i := range possibilities {
+		possibilities[i] = make([]PossibilitiesCount, area.Width)
+		for j := range possibilities[i] {
+			row, col := area.Row+i, area.Col+j
+			possibilities[i][j] = PossibilitiesCount{
+				possibilities: pt.Count(row, col),
+				alreadyPlaced: pt.board.Get(row, col) != nil,
+			}
+		}
+	}
+	return possibilities
+}
+
+// AvailablePositions returns the empty cells next to placed tiles that at
+// least one pile tile fits, in row-major order, with their Options
+func (pt *PossibilityTracker) AvailablePositions() []PositionWithPossibilities {
+	var positions []PositionWithPossibilities
+	for pos := range pt.frontier {
+		if options := pt.Options(pos.row, pos.col); options > 0 {
+			positions = append(positions, PositionWithPossibilities{
+				row:           pos.row,
+				col:           pos.col,
+				possibilities: options,
+			})
+		}
+	}
+
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i].row != positions[j].row {
+			return positions[i].row < positions[j].row
+		}
+		return positions[i].col < positions[j].col
+	})
+	return positions
+}
+
+func (pt *PossibilityTracker) updateCell(row, col int) {
+	pos := cellPosition{row, col}
+	delete(pt.fits, pos)
+	if pt.board.Get(row, col) != nil || !pt.board.Contains(row, col) || !hasAdjacentTile(pt.board, row, col) {
+		delete(pt.frontier, pos)
+		return
+	}
+	pt.frontier[pos] = pt.board.PatternAt(row, col)
+}
+
+// signature returns every pattern that t matches in at least one rotation,
+// with the number of its distinct rotations that do. Only used when every
+// border only touches itself, as there are 2^sides patterns per rotation
+// then.
+func (pt *PossibilityTracker) signature(t tile.Tile) []patternFit {
+	key := t.TypeCode()
+	if signature, ok := pt.signatures[key]; ok {
+		return signature
+	}
+
+	index := make(map[tile.Pattern]int)
+	var signature []patternFit
+	for _, rotated := range t.DistinctRotations() {
+		// Every side is either a wildcard or shows the borders of the tile
+		patterns := []tile.Pattern{{}}
+		for side := range tile.Side(t.Sides()) {
+			for _, pattern := range patterns {
+				patterns = append(patterns, pattern.WithSegments(side, rotated.Segments(side)))
+			}
+		}
+
+		for _, pattern := range patterns {
+			if i, ok := index[pattern]; ok {
+				signature[i].rotations++
+			} else {
+				index[pattern] = len(signature)
+				signature = append(signature, patternFit{pattern, 1})
+			}
+		}
+	}
+
+	pt.signatures[key] = signature
+	return signature
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+func TestPossibilityTrackerMatchesCountPossibilities(t *testing.T) {
+	t.Run("exact borders", testPossibilityTrackerMatchesCountPossibilities)
+
+	t.Run("compatible borders", func(t *testing.T) {
+		types := tile.BorderTypes()
+		types[tile.Stream].Touches = "R"
+		types = append(types,
+			tile.BorderType{Char: 'M', Name: "male", Touches: "W", Socket: true},
+			tile.BorderType{Char: 'W', Name: "female", Socket: true})
+		if err := tile.SetBorderTypes(types); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { tile.SetBorderTypes(tile.DefaultBorderTypes) })
+		testPossibilityTrackerMatchesCountPossibilities(t)
+	})
+
+	t.Run("a border touching every other", func(t *testing.T) {
+		types := append(tile.BorderTypes(), tile.BorderType{Char: 'B', Name: "bridge", Touches: "FCSR"})
+		if err := tile.SetBorderTypes(types); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { tile.SetBorderTypes(tile.DefaultBorderTypes) })
+		testPossibilityTrackerMatchesCountPossibilities(t)
+	})
+}
+
+func testPossibilityTrackerMatchesCountPossibilities(t *testing.T) {
+	rng := rand.New(rand.NewSource(11))
+	pile := Pile{}
+	for range 40 {
+		pile = append(pile, tile.CreateRandomTile(rng))
+	}
+
+	board := NewBoard(7, 6)
+	tracker := NewPossibilityTracker(&board, &pile)
+
+	check := func(step string) {
+		t.Helper()
+		if expected := board.CountPossibilities(&pile); !reflect.DeepEqual(tracker.Possibilities(), expected) {
+			t.Fatalf("%s: expected possibilities:\n%v\ngot:\n%v", step, expected, tracker.Possibilities())
+		}
+		if expected := getSortedAvailablePositions(&board, &pile, nil); !reflect.DeepEqual(sortPositions(tracker.AvailablePositions(), nil), expected) {
+			t.Fatalf("%s: expected positions %v, got %v", step, expected, tracker.AvailablePositions())
+		}
+	}
+	check("initial board")
+
+	type placement struct {
+		row, col int
+		tile     tile.Tile
+	}
+	var placements []placement
+	for range 15 {
+		row, col := rng.Intn(board.Height()), rng.Intn(board.Width())
+		if board.Get(row, col) != nil {
+			continue
+		}
+		placed := pile.RemoveAt(rng.Intn(pile.Size()))
+		rotated := placed.Rotate(rng.Intn(4))
+		board.Set(row, col, &rotated)
+		tracker.TakeTile(placed)
+		tracker.CellChanged(row, col)
+		placements = append(placements, placement{row, col, placed})
+		check("after placement")
+	}
+
+	for i := len(placements) - 1; i >= 0; i-- {
+		p := placements[i]
+		board.Set(p.row, p.col, nil)
+		pile = append(pile, p.tile)
+		tracker.ReturnTile(p.tile)
+		tracker.CellChanged(p.row, p.col)
+		check("after removal")
+	}
+}
+
+func TestAvailablePositionsCountDistinctOptions(t *testing.T) {
+	// Ten copies of one tile fit on the right, two different tiles on the left
+	board := BoardFromString("[    ][FCFS][    ]")
+	pile := Pile{tile.CreateTile("SSSS"), tile.CreateTile("FSFS")}
+	for range 10 {
+		pile = append(pile, tile.CreateTile("CCCC"))
+	}
+
+	tracker := NewPossibilityTracker(&board, &pile)
+	expected := []PositionWithPossibilities{{row: 0, col: 0, possibilities: 2}, {row: 0, col: 2, possibilities: 1}}
+	if positions := tracker.AvailablePositions(); !reflect.DeepEqual(positions, expected) {
+		t.Errorf("Expected positions %v, got %v", expected, positions)
+	}
+	if tracker.Count(0, 2) != 10 {
+		t.Errorf("Expected 10 tiles to fit on the right, got %d", tracker.Count(0, 2))
+	}
+}
+
+func newLargeBoard(size int) (Board, Pile) {
+	rng := rand.New(rand.NewSource(1))
+	pile := Pile{}
+	for range size * size / 10 {
+		pile = append(pile, tile.CreateRandomTile(rng))
+	}
+
+	board := NewBoard(size, size)
+	for row := range size {
+		for col := range size {
+			if (row+col)%3 == 0 {
+				t := tile.CreateRandomTile(rng)
+				board.Set(row, col, &t)
+			}
+		}
+	}
+	return board, pile
+}
+
+func BenchmarkCountPossibilities100x100(b *testing.B) {
+	board, pile := newLargeBoard(100)
+
+	for b.Loop() {
+		board.CountPossibilities(&pile)
+	}
+}
+
+func BenchmarkPossibilityTrackerStep100x100(b *testing.B) {
+	board, pile := newLargeBoard(100)
+	tracker := NewPossibilityTracker(&board, &pile)
+
+	// One solver step: place a tile, look up the candidate positions and undo
+	for b.Loop() {
+		placed := pile.RemoveAt(0)
+		board.Set(50, 51, &placed)
+		tracker.TakeTile(placed)
+		tracker.CellChanged(50, 51)
+
+		tracker.AvailablePositions()
+
+		board.Set(50, 51, nil)
+		pile.InsertAt(0, placed)
+		tracker.ReturnTile(placed)
+		tracker.CellChanged(50, 51)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/vakrim/carcassonne-wave-collapse/scoring"
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+// ScoringFeatures describes the features of the placed tiles for the scoring
+// package: the board features of Features in the same order, followed by one
+// monastery per tile that has one. Fields touch the cities next to them on
+// any of their tiles.
+func (b *Board) ScoringFeatures() []scoring.Feature {
+	return b.scoringFeatures(nil)
+}
+
+// scoringFeatures describes the features of the placed tiles with the
+// meeples that stand on them
+func (b *Board) scoringFeatures(meeples []Meeple) []scoring.Feature {
+	boardFeatures := b.Features()
+	index := make(map[int]int, len(boardFeatures))
+	features := make([]scoring.Feature, len(boardFeatures))
+	for i, feature := range boardFeatures {
+		index[feature.ID] = i
+		features[i] = scoring.Feature{
+			Kind:     scoringKind(feature.Border),
+			Label:    fmt.Sprintf("at [%d][%d]", feature.Cells[0].row, feature.Cells[0].col),
+			Tiles:    len(feature.Cells),
+			Pennants: feature.Pennants,
+			Complete: feature.Closed(),
+		}
+	}
+
+	for field, cities := range b.fieldCities() {
+		for _, city := range cities {
+			features[index[field]].Cities = append(features[index[field]].Cities, index[city])
+		}
+		slices.Sort(features[index[field]].Cities)
+	}
+
+	positions := make([]cellPosition, 0, len(b.tiles))
+	for pos, t := range b.tiles {
+		if t.Monastery() {
+			positions = append(positions, pos)
+		}
+	}
+	slices.SortFunc(positions, compareCells)
+	monasteries := make(map[cellPosition]int, len(positions))
+	for _, pos := range positions {
+		monasteries[pos] = len(features)
+		tiles, complete := b.neighbourhood(pos.row, pos.col)
+		features = append(features, scoring.Feature{
+			Kind:     scoring.Monastery,
+			Label:    fmt.Sprintf("at [%d][%d]", pos.row, pos.col),
+			Tiles:    tiles,
+			Complete: complete,
+		})
+	}
+
+	tracker := b.trackFeatures()
+	for _, meeple := range meeples {
+		// A meeple on a monastery or tile that is not there stands on nothing
+		pos := cellPosition{meeple.Row, meeple.Col}
+		i, ok := monasteries[pos]
+		if !meeple.Monastery {
+			var slots []int
+			if slots, ok = tracker.slots[pos]; ok {
+				i = index[tracker.find(slots[slotIndex(meeple.Slot)])]
+			}
+		}
+		if !ok {
+			continue
+		}
+		features[i].Meeples = append(features[i].Meeples, meeple.Player)
+	}
+	return features
+}
+
+// Score scores the board as if the game ended now
+func (b *Board) Score(rules scoring.Rules) scoring.Score {
+	return rules.Score(b.ScoringFeatures())
+}
+
+// scoringKind returns how a feature of the border scores, by the role of the
+// border type rather than its place in the registry
+func scoringKind(border tile.Border) scoring.Kind {
+	switch border.Role() {
+	case tile.CityRole:
+		return scoring.City
+	case tile.RoadRole:
+		return scoring.Road
+	case tile.FieldRole:
+		return scoring.Field
+	default:
+		return scoring.Other
+	}
+}
+
+// fieldCities returns the IDs of the cities next to every field, found by
+// walking round the edge of every tile: a field touches a city where a slot
+// of one follows a slot of the other
+func (b *Board) fieldCities() map[int][]int {
+	features := b.trackFeatures()
+	cities := make(map[int][]int)
+	for _, slots := range features.slots {
+		for i := range slots {
+			a, c := features.find(slots[i]), features.find(slots[(i+1)%len(slots)])
+			if features.border[a].Role() == tile.CityRole {
+				a, c = c, a
+			}
+			if features.border[a].Role() == tile.FieldRole && features.border[c].Role() == tile.CityRole && !slices.Contains(cities[a], c) {
+				cities[a] = append(cities[a], c)
+			}
+		}
+	}
+	return cities
+}
+
+// neighbourhood counts the placed tiles of a cell and the cells around it,
+// diagonals included on a square board, and reports whether all of them are
+// placed. Next to an edge or on a small torus there are too few cells for
+// the neighbourhood to be complete.
+func (b *Board) neighbourhood(row, col int) (int, bool) {
+	var cells []cellPosition
+	for side := range tile.Side(b.Sides()) {
+		neighbourRow, neighbourCol, ok := b.Neighbour(row, col, side)
+		if !ok {
+			continue
+		}
+		cells = append(cells, cellPosition{neighbourRow, neighbourCol})
+		if b.Sides() == int(tile.SideCount) {
+			// The next side round leads to the corner
+			if cornerRow, cornerCol, ok := b.Neighbour(neighbourRow, neighbourCol, (side+1)%tile.SideCount); ok {
+				cells = append(cells, cellPosition{cornerRow, cornerCol})
+			}
+		}
+	}
+
+	// A small torus may repeat cells, or wrap round to the cell itself
+	slices.SortFunc(cells, compareCells)
+	cells = slices.DeleteFunc(slices.Compact(cells), func(pos cellPosition) bool {
+		return pos == cellPosition{row, col}
+	})
+
+	tiles := 1
+	for _, pos := range cells {
+		if b.Get(pos.row, pos.col) != nil {
+			tiles++
+		}
+	}
+	expected := 9
+	if b.Sides() != int(tile.SideCount) {
+		expected = b.Sides() + 1
+	}
+	return tiles, tiles == expected
+}
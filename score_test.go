@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vakrim/carcassonne-wave-collapse/scoring"
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+func TestScoreBoard(t *testing.T) {
+	// A closed city with a pennant, a monastery with two tiles around it and
+	// the fields on either side of the city
+	board := UnboundedBoardFromString("[FFCF B+][FFFF M]\n[CFFF][    ]")
+	features := board.ScoringFeatures()
+	score := board.Score(scoring.DefaultRules)
+
+	points := make(map[scoring.Kind]int)
+	for _, featureScore := range score.Features {
+		points[features[featureScore.Feature].Kind] += featureScore.Points
+	}
+	if points[scoring.City] != 6 || points[scoring.Monastery] != 3 || points[scoring.Field] != 6 {
+		t.Errorf("Expected 6 points for the city, 3 for the monastery and 6 for the two fields, got %v", points)
+	}
+	if score.Total != 15 {
+		t.Errorf("Expected 15 points in total, got %d", score.Total)
+	}
+}
+
+func TestFieldsOnlyTouchCitiesNextToThem(t *testing.T) {
+	// The road keeps the field below it away from the city
+	board := BoardFromString("[C(FRF)F(FRF) T RlLr RrBLl]")
+	features := board.ScoringFeatures()
+	touching := 0
+	for _, feature := range features {
+		if feature.Kind == scoring.Field {
+			touching += len(feature.Cities)
+		}
+	}
+	if touching != 1 {
+		t.Errorf("Expected a single field next to the city, got %v", features)
+	}
+}
+
+func TestScoringFollowsBorderRoles(t *testing.T) {
+	// A lake listed first takes the index fields have by default
+	types, err := tile.ParseBorderTypes(strings.NewReader("L lake #4682b4\nF field #228b22\nC city #8b4513\nR road #808080\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tile.SetBorderTypes(types); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tile.SetBorderTypes(tile.DefaultBorderTypes) })
+
+	board := UnboundedBoardFromString("[FFCF][LLLL]\n[CFFF][    ]")
+	points := make(map[scoring.Kind]int)
+	features := board.ScoringFeatures()
+	for _, featureScore := range board.Score(scoring.DefaultRules).Features {
+		points[features[featureScore.Feature].Kind] += featureScore.Points
+	}
+	if points[scoring.City] != 4 || points[scoring.Field] != 6 || points[scoring.Other] != 0 {
+		t.Errorf("Expected 4 points for the city, 6 for the fields and none for the lake, got %v", points)
+	}
+
+	// Whatever its index, the lake is no field
+	lake, _ := board.FeatureAt(0, 1, tile.Slot{})
+	if scoringKind(lake.Border) != scoring.Other {
+		t.Errorf("Expected the lake to score as other, got %v", scoringKind(lake.Border))
+	}
+}
+
+func TestMeeplesOnMissingMonasteriesAreSkipped(t *testing.T) {
+	board := UnboundedBoardFromString("[FFCF][FFFF M]")
+	meeples := []Meeple{
+		{Player: 0, Row: 0, Col: 0, Monastery: true},
+		{Player: 1, Row: 1, Col: 1, Slot: tile.Slot{Side: tile.SideTop}},
+		{Player: 2, Row: 0, Col: 1, Monastery: true},
+	}
+	for _, feature := range board.scoringFeatures(meeples) {
+		if len(feature.Meeples) > 0 && (feature.Kind != scoring.Monastery || feature.Meeples[0] != 2) {
+			t.Errorf("Expected only the meeple on the monastery to count, got %v on %s", feature.Meeples, feature.Label)
+		}
+	}
+}
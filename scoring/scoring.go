@@ -0,0 +1,180 @@
+// Package scoring scores the features of a Carcassonne board. It knows
+// nothing about tiles or boards: the caller describes every feature, see
+// Feature, so that any board representation can be scored.
+package scoring
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Kind is the kind of a feature as far as scoring is concerned
+type Kind int
+
+const (
+	City Kind = iota
+	Road
+	Monastery
+	Field
+	// Other features, such as streams, are worth nothing
+	Other
+)
+
+var kindNames = []string{"city", "road", "monastery", "field", "other"}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		panic("Unknown feature kind")
+	}
+	return kindNames[k]
+}
+
+// Feature describes a feature of a board for scoring
+type Feature struct {
+	Kind  Kind
+	Label string // identifies the feature in a breakdown, e.g. by its cells
+
+	// Tiles counts the tiles the feature covers. For a monastery these are
+	// the tiles of its neighbourhood, its own included.
+	Tiles    int
+	Pennants int
+	Complete bool
+
+	// Cities holds the indexes of the cities a field touches, in the list
+	// of features being scored
+	Cities []int
+
+	// Meeples holds the player of every meeple on the feature
+	Meeples []int
+}
+
+// Rules holds the points of every kind of feature
+type Rules struct {
+	CityTile              int // per tile of a complete city
+	CityPennant           int // per pennant of a complete city
+	IncompleteCityTile    int
+	IncompleteCityPennant int
+	RoadTile              int // per tile of a road, complete or not
+	MonasteryTile         int // per tile around and under a monastery
+	FarmerCity            int // per complete city a field touches
+}
+
+// DefaultRules are the rules of Carcassonne for a game that has ended, so
+// incomplete features score as well
+var DefaultRules = Rules{
+	CityTile:              2,
+	CityPennant:           2,
+	IncompleteCityTile:    1,
+	IncompleteCityPennant: 1,
+	RoadTile:              1,
+	MonasteryTile:         1,
+	FarmerCity:            3,
+}
+
+// FeatureScore is the score of a single feature
+type FeatureScore struct {
+	Feature int // index in the list of scored features
+	Points  int
+
+	// Winners holds the players with the most meeples on the feature, who
+	// each get the points
+	Winners []int
+}
+
+// Score is the breakdown of the points of a board
+type Score struct {
+	Features []FeatureScore
+
+	// Total sums the points of every feature, whoever holds it
+	Total   int
+	Players map[int]int
+}
+
+// Points returns what a feature is worth. features is the whole list the
+// feature is part of, which fields need to look up their cities.
+func (r Rules) Points(feature Feature, features []Feature) int {
+	switch feature.Kind {
+	case City:
+		if feature.Complete {
+			return feature.Tiles*r.CityTile + feature.Pennants*r.CityPennant
+		}
+		return feature.Tiles*r.IncompleteCityTile + feature.Pennants*r.IncompleteCityPennant
+	case Road:
+		return feature.Tiles * r.RoadTile
+	case Monastery:
+		return feature.Tiles * r.MonasteryTile
+	case Field:
+		points := 0
+		for _, city := range feature.Cities {
+			if features[city].Kind == City && features[city].Complete {
+				points += r.FarmerCity
+			}
+		}
+		return points
+	default:
+		return 0
+	}
+}
+
+// Score scores every feature and hands the points to the players with the
+// most meeples on it
+func (r Rules) Score(features []Feature) Score {
+	score := Score{Players: make(map[int]int)}
+	for i, feature := range features {
+		featureScore := FeatureScore{Feature: i, Points: r.Points(feature, features), Winners: winners(feature.Meeples)}
+		score.Total += featureScore.Points
+		for _, player := range featureScore.Winners {
+			score.Players[player] += featureScore.Points
+		}
+		score.Features = append(score.Features, featureScore)
+	}
+	return score
+}
+
+// winners returns the players with the most meeples, in order
+func winners(meeples []int) []int {
+	counts := make(map[int]int)
+	most := 0
+	for _, player := range meeples {
+		counts[player]++
+		most = max(most, counts[player])
+	}
+
+	var players []int
+	for player, count := range counts {
+		if count == most {
+			players = append(players, player)
+		}
+	}
+	slices.Sort(players)
+	return players
+}
+
+// Describe writes a feature and its points for a breakdown, such as
+// "complete city at [0][0] of 3 tiles and 1 pennant: 8 points"
+func (s FeatureScore) Describe(feature Feature) string {
+	description := feature.Kind.String()
+	if feature.Complete && feature.Kind != Field {
+		description = "complete " + description
+	}
+	if feature.Label != "" {
+		description += " " + feature.Label
+	}
+
+	if feature.Kind == Field {
+		description += " next to " + quantity(len(feature.Cities), "city", "cities")
+	} else {
+		description += " of " + quantity(feature.Tiles, "tile", "tiles")
+	}
+	if feature.Pennants > 0 {
+		description += " and " + quantity(feature.Pennants, "pennant", "pennants")
+	}
+	return description + ": " + quantity(s.Points, "point", "points")
+}
+
+func quantity(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
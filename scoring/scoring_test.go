@@ -0,0 +1,42 @@
+package scoring
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPoints(t *testing.T) {
+	features := []Feature{
+		{Kind: City, Tiles: 3, Pennants: 1, Complete: true},
+		{Kind: City, Tiles: 3, Pennants: 1},
+		{Kind: Road, Tiles: 4},
+		{Kind: Monastery, Tiles: 9, Complete: true},
+		{Kind: Monastery, Tiles: 5},
+		{Kind: Field, Cities: []int{0, 1}},
+		{Kind: Other, Tiles: 7, Complete: true},
+	}
+	expected := []int{8, 4, 4, 9, 5, 3, 0}
+	for i, feature := range features {
+		if points := DefaultRules.Points(feature, features); points != expected[i] {
+			t.Errorf("Expected %d points for %s %d, got %d", expected[i], feature.Kind, i, points)
+		}
+	}
+}
+
+func TestScoreGoesToMostMeeples(t *testing.T) {
+	features := []Feature{
+		{Kind: Road, Tiles: 3, Meeples: []int{0, 1, 1}},
+		{Kind: Road, Tiles: 2, Meeples: []int{0, 1}},
+		{Kind: City, Tiles: 2, Complete: true},
+	}
+	score := DefaultRules.Score(features)
+	if score.Total != 9 || score.Players[0] != 2 || score.Players[1] != 5 {
+		t.Errorf("Expected 9 points with 2 for player 0 and 5 for player 1, got %+v", score)
+	}
+	if !slices.Equal(score.Features[1].Winners, []int{0, 1}) || len(score.Features[2].Winners) != 0 {
+		t.Errorf("Expected a tie on the second road and nobody on the city, got %+v", score.Features)
+	}
+	if description := score.Features[2].Describe(features[2]); description != "complete city of 2 tiles: 4 points" {
+		t.Errorf("Unexpected description %q", description)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// BoardShape decides which cells of a bounded board tiles may be placed on.
+// The other cells are blocked.
+type BoardShape int
+
+const (
+	// ShapeRectangle uses every cell of the board
+	ShapeRectangle BoardShape = iota
+	// ShapeCircle uses the cells inside the ellipse that touches the edges
+	ShapeCircle
+	// ShapeRing is a circle with a hole of half its size in the middle
+	ShapeRing
+	// ShapeL leaves out the top right quarter of the board
+	ShapeL
+)
+
+var boardShapeNames = []string{"rectangle", "circle", "ring", "l"}
+
+func (s BoardShape) String() string {
+	if s < 0 || int(s) >= len(boardShapeNames) {
+		panic("Unknown board shape")
+	}
+	return boardShapeNames[s]
+}
+
+func ParseBoardShape(name string) (BoardShape, error) {
+	for i, shapeName := range boardShapeNames {
+		if shapeName == name {
+			return BoardShape(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown board shape %q, expected one of %v", name, boardShapeNames)
+}
+
+// contains reports whether a cell of a width x height board is part of the
+// shape
+func (s BoardShape) contains(row, col, width, height int) bool {
+	// Distance of the cell centre from the board centre, where the edges are
+	// at distance 1
+	dy := (float64(row) + 0.5 - float64(height)/2) / (float64(height) / 2)
+	dx := (float64(col) + 0.5 - float64(width)/2) / (float64(width) / 2)
+	distance := math.Hypot(dx, dy)
+
+	switch s {
+	case ShapeRectangle:
+		return true
+	case ShapeCircle:
+		return distance <= 1
+	case ShapeRing:
+		return distance <= 1 && distance >= 0.5
+	case ShapeL:
+		return row >= height/2 || col < width/2
+	default:
+		panic("Unknown board shape")
+	}
+}
+
+// NewShapedBoard creates a bounded board whose cells outside the shape are
+// blocked
+func NewShapedBoard(width, height int, shape BoardShape) Board {
+	board := NewBoard(width, height)
+	for row := range height {
+		for col := range width {
+			if !shape.contains(row, col, width, height) {
+				board.Block(row, col)
+			}
+		}
+	}
+	return board
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+func TestShapedBoards(t *testing.T) {
+	tests := []struct {
+		shape   BoardShape
+		open    [][2]int
+		blocked [][2]int
+	}{
+		{ShapeRectangle, [][2]int{{0, 0}, {11, 11}, {6, 6}}, nil},
+		{ShapeCircle, [][2]int{{6, 6}, {0, 6}, {6, 11}}, [][2]int{{0, 0}, {11, 11}}},
+		{ShapeRing, [][2]int{{0, 6}, {6, 11}}, [][2]int{{0, 0}, {6, 6}}},
+		{ShapeL, [][2]int{{0, 0}, {11, 11}, {6, 6}}, [][2]int{{0, 11}, {5, 6}}},
+	}
+
+	for _, test := range tests {
+		board := NewShapedBoard(12, 12, test.shape)
+		for _, cell := range test.open {
+			if !board.Contains(cell[0], cell[1]) {
+				t.Errorf("Expected [%d][%d] to be part of the %s shape", cell[0], cell[1], test.shape)
+			}
+		}
+		for _, cell := range test.blocked {
+			if !board.Blocked(cell[0], cell[1]) {
+				t.Errorf("Expected [%d][%d] to be blocked on the %s shape", cell[0], cell[1], test.shape)
+			}
+		}
+	}
+}
+
+func TestBlockedCellsAreSkipped(t *testing.T) {
+	input := `[    ][####][    ]
+[    ][CFFF][####]`
+	board := BoardFromString(input)
+	if board.String() != input {
+		t.Errorf("Expected blocked cells to be drawn back, got:\n%s", board.String())
+	}
+	if board.CellCount() != 4 {
+		t.Errorf("Expected 4 cells on the board, got %d", board.CellCount())
+	}
+	if hasAdjacentTile(&board, 0, 1) {
+		t.Errorf("Expected a blocked cell not to count as next to a tile")
+	}
+
+	pile := Pile{tile.CreateTile("FFFF"), tile.CreateTile("FFFF")}
+	positions := getSortedAvailablePositions(&board, &pile, nil)
+	if len(positions) != 1 || positions[0].row != 1 || positions[0].col != 0 {
+		t.Errorf("Expected only [1][0] to be available, got %v", positions)
+	}
+
+	result := NewSolver(&board, &pile, SolverOptions{Propagate: true}).Solve()
+	if !result.Solved {
+		t.Fatalf("Expected the open cells to be filled, got error: %v", result.Err)
+	}
+	if board.Get(0, 1) != nil || board.Get(1, 2) != nil {
+		t.Errorf("Expected blocked cells to stay empty, got:\n%s", board.String())
+	}
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+type SolverEventType int
+
+const (
+	// TilePlaced is emitted after a tile has been put on the board
+	TilePlaced SolverEventType = iota
+	// TileRemoved is emitted after a placement has been undone while backtracking
+	TileRemoved
+)
+
+func (e SolverEventType) String() string {
+	switch e {
+	case TilePlaced:
+		return "placed"
+	case TileRemoved:
+		return "removed"
+	default:
+		panic("Unknown solver event type")
+	}
+}
+
+// SolverEvent describes a single change the solver made to the board
+type SolverEvent struct {
+	Type     SolverEventType
+	Row, Col int
+	Tile     *tile.Tile
+	Depth    int
+}
+
+type SolverStats struct {
+	Placements int
+	Backtracks int
+	// Pruned counts placements rejected by constraint propagation
+	Pruned   int
+	MaxDepth int
+	Duration time.Duration
+}
+
+type SolveResult struct {
+	Solved         bool
+	Err            error
+	TilesRemaining int
+	Stats          SolverStats
+}
+
+// Strategy decides what the solver commits to first at every step
+type Strategy int
+
+const (
+	// TileFirst takes the tiles allowed by the draw policy and searches every
+	// available position for them, most constrained positions first
+	TileFirst Strategy = iota
+	// CellFirst is classic wave function collapse: it picks the single empty
+	// cell with the fewest distinct orientations of the tile types left and
+	// collapses it to one of them
+	CellFirst
+)
+
+var strategyNames = []string{"tile", "cell"}
+
+func (s Strategy) String() string {
+	if s < 0 || int(s) >= len(strategyNames) {
+		panic("Unknown strategy")
+	}
+	return strategyNames[s]
+}
+
+func ParseStrategy(name string) (Strategy, error) {
+	for i, strategyName := range strategyNames {
+		if strategyName == name {
+			return Strategy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown strategy %q, expected one of %v", name, strategyNames)
+}
+
+type SolverOptions struct {
+	// Rand breaks ties between positions with the same number of
+	// possibilities and drives the weighted draw policy. When nil, ties are
+	// resolved in row-major order.
+	Rand *rand.Rand
+
+	// DrawPolicy decides which tiles from the pile may be placed next
+	DrawPolicy DrawPolicy
+
+	// Strategy decides whether the solver searches positions for a tile or
+	// tiles for the most constrained position
+	Strategy Strategy
+
+	// Propagate keeps per-cell domains up to date after every placement and
+	// rejects placements that lead to a contradiction before searching on
+	Propagate bool
+}
+
+// Solver places all tiles from the pile onto the board without any
+// dependency on the visualization. Observers can follow its progress by
+// subscribing to its events.
+type Solver struct {
+	board     *Board
+	pile      *Pile
+	counted   *CountedPile // the tiles of pile by type, to draw candidates from
+	options   SolverOptions
+	domains   *Domains
+	tracker   *PossibilityTracker
+	listeners []func(SolverEvent)
+	stats     SolverStats
+}
+
+func NewSolver(board *Board, pile *Pile, options SolverOptions) *Solver {
+	return &Solver{
+		board:   board,
+		pile:    pile,
+		options: options,
+	}
+}
+
+// Subscribe registers a listener that is called synchronously for every
+// solver event, so a slow listener slows the solver down as well.
+func (s *Solver) Subscribe(listener func(SolverEvent)) {
+	s.listeners = append(s.listeners, listener)
+}
+
+func (s *Solver) Solve() SolveResult {
+	s.stats = SolverStats{}
+	start := time.Now()
+
+	s.tracker = NewPossibilityTracker(s.board, s.pile)
+	s.counted = NewCountedPile(s.pile)
+
+	var err error
+	if s.options.Propagate {
+		s.domains, err = NewDomains(s.board, s.pile)
+	}
+	if err == nil {
+		err = s.solve(0)
+	}
+
+	s.stats.Duration = time.Since(start)
+	return SolveResult{
+		Solved:         err == nil,
+		Err:            err,
+		TilesRemaining: s.pile.Size(),
+		Stats:          s.stats,
+	}
+}
+
+func (s *Solver) solve(depth int) error {
+	// Check if all tiles are used
+	if !s.pile.hasMoreTiles() {
+		return nil // Success - all tiles used
+	}
+
+	sortedPositions := sortPositions(s.availablePositions(), s.options.Rand)
+
+	if len(sortedPositions) == 0 {
+		return fmt.Errorf("no more valid positions to place remaining %d tiles", s.pile.Size())
+	}
+
+	if s.options.Strategy == CellFirst {
+		// Collapse only the lowest-entropy cell. If none of its tiles lead to
+		// a solution, the contradiction is passed up to the previous cell.
+		sortedPositions = sortedPositions[:1]
+	}
+
+	candidates := s.options.DrawPolicy.candidates(s.pile, s.counted, s.options.Rand)
+
+	for _, pos := range sortedPositions {
+		pattern := s.tracker.Pattern(pos.row, pos.col)
+
+		for _, candidate := range candidates {
+			// Try every rotation of the candidate before moving on to the next
+			// one. Rotations that look the same would only repeat the search.
+			for _, rotatedTile := range candidate.DistinctRotations() {
+				if !rotatedTile.Matches(pattern) {
+					continue
+				}
+
+				// Place the tile in the matching orientation
+				placement := s.place(pos, &rotatedTile)
+
+				mark, err := s.propagate(pos.row, pos.col, &rotatedTile)
+				if err != nil {
+					// Dead end found by propagation, no need to search it
+					s.unplace(placement)
+					s.stats.Pruned++
+					continue
+				}
+
+				s.stats.Placements++
+				s.stats.MaxDepth = max(s.stats.MaxDepth, depth+1)
+				s.emit(SolverEvent{Type: TilePlaced, Row: pos.row, Col: pos.col, Tile: &rotatedTile, Depth: depth})
+
+				err = s.solve(depth + 1)
+				if err == nil {
+					return nil // solved!
+				}
+
+				s.undoPropagation(mark)
+				s.unplace(placement)
+				s.stats.Backtracks++
+				s.emit(SolverEvent{Type: TileRemoved, Row: pos.row, Col: pos.col, Tile: &rotatedTile, Depth: depth})
+			}
+		}
+	}
+
+	if s.options.Strategy == CellFirst {
+		pos := sortedPositions[0]
+		return fmt.Errorf("cell [%d][%d] cannot be collapsed to any of the remaining %d tiles", pos.row, pos.col, s.pile.Size())
+	}
+	if s.options.DrawPolicy == DrawTop {
+		return fmt.Errorf("current tile %s cannot be placed in any available position", s.pile.PeekTop().String())
+	}
+	return fmt.Errorf("none of the remaining %d tiles can be placed in any available position", s.pile.Size())
+}
+
+// solverPlacement is a tile the solver has moved from the pile onto the
+// board, with what it takes to undo the move
+type solverPlacement struct {
+	pos   PositionWithPossibilities
+	index int       // of the tile in the pile
+	tile  tile.Tile // as it was in the pile
+	mark  int       // of the board before the tile was placed
+}
+
+// place moves the first copy of the type of rotated in the pile onto the
+// board in the given orientation
+func (s *Solver) place(pos PositionWithPossibilities, rotated *tile.Tile) solverPlacement {
+	placement := solverPlacement{pos: pos, index: s.pile.indexOf(rotated), mark: s.board.Mark()}
+	placement.tile = s.pile.RemoveAt(placement.index)
+	s.board.Set(pos.row, pos.col, rotated)
+	s.counted.Remove(placement.tile)
+	s.tracker.TakeTile(placement.tile)
+	s.tracker.CellChanged(pos.row, pos.col)
+	return placement
+}
+
+// unplace undoes place
+func (s *Solver) unplace(placement solverPlacement) {
+	s.board.Undo(placement.mark)
+	s.pile.InsertAt(placement.index, placement.tile)
+	s.counted.Add(placement.tile)
+	s.tracker.ReturnTile(placement.tile)
+	s.tracker.CellChanged(placement.pos.row, placement.pos.col)
+}
+
+// Possibilities returns the possibility counts of the board being solved
+// without recomputing them from the pile
+func (s *Solver) Possibilities() [][]PossibilitiesCount {
+	return s.PossibilitiesIn(s.board.Bounds())
+}
+
+// PossibilitiesIn returns the possibility counts of an area of the board, see
+// Board.CountPossibilitiesIn
+func (s *Solver) PossibilitiesIn(area Rect) [][]PossibilitiesCount {
+	if s.tracker == nil {
+		return s.board.CountPossibilitiesIn(area, s.pile)
+	}
+	return s.tracker.PossibilitiesIn(area)
+}
+
+// propagate narrows the domains after a placement when propagation is
+// enabled. On error the domains have already been restored.
+func (s *Solver) propagate(row, col int, placed *tile.Tile) (int, error) {
+	if s.domains == nil {
+		return 0, nil
+	}
+	mark := s.domains.Mark()
+	if err := s.domains.Place(row, col, placed); err != nil {
+		s.domains.Undo(mark)
+		return 0, err
+	}
+	return mark, nil
+}
+
+func (s *Solver) undoPropagation(mark int) {
+	if s.domains != nil {
+		s.domains.Undo(mark)
+	}
+}
+
+// availablePositions returns the cells a tile may go in next, with the
+// orientations left for each: the domain sizes when propagating, which also
+// leave out the cells that no tile can take any more
+func (s *Solver) availablePositions() []PositionWithPossibilities {
+	positions := s.tracker.AvailablePositions()
+	if s.domains == nil {
+		return positions
+	}
+
+	available := positions[:0]
+	for _, pos := range positions {
+		if pos.possibilities = s.domains.Size(pos.row, pos.col); pos.possibilities > 0 {
+			available = append(available, pos)
+		}
+	}
+	return available
+}
+
+func (s *Solver) emit(event SolverEvent) {
+	for _, listener := range s.listeners {
+		listener(event)
+	}
+}
+
+type PositionWithPossibilities struct {
+	row, col      int
+	possibilities int
+}
+
+// getSortedAvailablePositions returns the empty positions next to placed tiles
+// that at least one tile from the pile fits, fewest distinct orientations of
+// the tile types in the pile first. Ties are broken by rng, or kept in
+// row-major order when rng is nil.
+func getSortedAvailablePositions(board *Board, pile *Pile, rng *rand.Rand) []PositionWithPossibilities {
+	area := board.Area()
+	types := NewCountedPile(pile).Types()
+	var positions []PositionWithPossibilities
+
+	// Collect all valid positions
+	for row := area.Row; row < area.Row+area.Height; row++ {
+		for col := area.Col; col < area.Col+area.Width; col++ {
+			if board.Get(row, col) != nil || !hasAdjacentTile(board, row, col) {
+				continue
+			}
+			pattern := board.PatternAt(row, col)
+			options := 0
+			for _, t := range types {
+				options += matchingRotations(t, pattern)
+			}
+			if options > 0 {
+				positions = append(positions, PositionWithPossibilities{
+					row:           row,
+					col:           col,
+					possibilities: options,
+				})
+			}
+		}
+	}
+
+	return sortPositions(positions, rng)
+}
+
+// sortPositions orders positions by possibilities, least first. Ties are
+// broken by rng, or keep their order when rng is nil.
+func sortPositions(positions []PositionWithPossibilities, rng *rand.Rand) []PositionWithPossibilities {
+	if rng != nil {
+		rng.Shuffle(len(positions), func(i, j int) {
+			positions[i], positions[j] = positions[j], positions[i]
+		})
+	}
+
+	// Sort by possibilities (ascending - least possibilities first)
+	sort.SliceStable(positions, func(i, j int) bool {
+		return positions[i].possibilities < positions[j].possibilities
+	})
+
+	return positions
+}
+
+// hasAdjacentTile reports whether a cell of the board has a placed tile next
+// to it. Blocked cells never do, as no tile can go there.
+func hasAdjacentTile(board *Board, row, col int) bool {
+	if !board.Contains(row, col) {
+		return false
+	}
+	for side := range tile.Side(board.Sides()) {
+		neighbourRow, neighbourCol, ok := board.Neighbour(row, col, side)
+		if ok && board.Get(neighbourRow, neighbourCol) != nil {
+			return true
+		}
+	}
+	return false
+}
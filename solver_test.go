@@ -0,0 +1,330 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+func TestSolverPlacesAllTiles(t *testing.T) {
+	board := BoardFromString(`[    ][    ][    ]
+[    ][CFFF][    ]
+[    ][    ][    ]`)
+	pile := Pile{
+		tile.CreateTile("FFCF"),
+		tile.CreateTile("RFRF"),
+		tile.CreateTile("FFFF"),
+	}
+
+	var placed, removed int
+	solver := NewSolver(&board, &pile, SolverOptions{})
+	solver.Subscribe(func(event SolverEvent) {
+		switch event.Type {
+		case TilePlaced:
+			placed++
+		case TileRemoved:
+			removed++
+		}
+	})
+
+	result := solver.Solve()
+
+	if !result.Solved || result.Err != nil {
+		t.Fatalf("Expected the board to be solved, got error: %v", result.Err)
+	}
+	if result.TilesRemaining != 0 || pile.Size() != 0 {
+		t.Errorf("Expected no tiles remaining, got %d", result.TilesRemaining)
+	}
+	if placed != result.Stats.Placements || removed != result.Stats.Backtracks {
+		t.Errorf("Expected events to match stats, got %d/%d events and %d/%d stats",
+			placed, removed, result.Stats.Placements, result.Stats.Backtracks)
+	}
+	if result.Stats.Placements-result.Stats.Backtracks != 3 {
+		t.Errorf("Expected 3 tiles to stay on the board, got %d", result.Stats.Placements-result.Stats.Backtracks)
+	}
+
+	for pos, placed := range board.tiles {
+		if !placed.MatchesQuery(board.GetTilePattern(pos.row, pos.col)) {
+			t.Errorf("Tile %s at [%d][%d] does not match its neighbours", placed.String(), pos.row, pos.col)
+		}
+	}
+}
+
+func TestSolverGrowsUnboundedBoard(t *testing.T) {
+	board := NewUnboundedBoard()
+	start := tile.CreateTile("RFRF")
+	board.Set(0, 0, &start)
+
+	pile := Pile{}
+	for range 10 {
+		pile = append(pile, tile.CreateTile("RFRF"))
+	}
+	pile = append(pile, tile.CreateTile("FFFF"))
+
+	result := NewSolver(&board, &pile, SolverOptions{Propagate: true}).Solve()
+
+	if !result.Solved {
+		t.Fatalf("Expected the board to grow to fit every tile, got error: %v", result.Err)
+	}
+	if len(board.tiles) != 12 {
+		t.Errorf("Expected 12 tiles on the board, got %d", len(board.tiles))
+	}
+}
+
+func TestSolverReportsUnplaceableTile(t *testing.T) {
+	board := BoardFromString(`[    ][CCCC][    ]`)
+	pile := Pile{
+		tile.CreateTile("FFFF"),
+	}
+
+	result := NewSolver(&board, &pile, SolverOptions{}).Solve()
+
+	if result.Solved || result.Err == nil {
+		t.Fatalf("Expected the solver to fail")
+	}
+	if result.TilesRemaining != 1 {
+		t.Errorf("Expected 1 tile remaining, got %d", result.TilesRemaining)
+	}
+	if board.String() != `[    ][CCCC][    ]` {
+		t.Errorf("Expected the board to be left unchanged, got:\n%s", board.String())
+	}
+}
+
+func TestSolverUsesCompatibleBorders(t *testing.T) {
+	types := append(tile.BorderTypes(),
+		tile.BorderType{Char: 'M', Name: "male", Touches: "W", Socket: true},
+		tile.BorderType{Char: 'W', Name: "female", Socket: true})
+	if err := tile.SetBorderTypes(types); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tile.SetBorderTypes(tile.DefaultBorderTypes) })
+
+	// The female side of the pile tile only fits against the male side of
+	// the pinned one, so it has to be turned round
+	board := BoardFromString(`[FMFF][    ]`)
+	pile := Pile{tile.CreateTile("FWFF")}
+	for _, propagate := range []bool{false, true} {
+		board := board.Clone()
+		pile := pile.Clone()
+		result := NewSolver(board, &pile, SolverOptions{Propagate: propagate}).Solve()
+		if !result.Solved {
+			t.Fatalf("Expected the sockets to fit together, got error: %v", result.Err)
+		}
+		if board.String() != `[FMFF][FFFW]` {
+			t.Errorf("Expected the female side to face the male one, got:\n%s", board.String())
+		}
+	}
+}
+
+func TestSolverIsReproducibleWithSeed(t *testing.T) {
+	solve := func(seed int64) string {
+		rng := rand.New(rand.NewSource(seed))
+		pile := Pile{}
+		for range 20 {
+			pile = append(pile, tile.CreateRandomTile(rng))
+		}
+		pile.Shuffle(rng)
+
+		board := NewBoard(9, 9)
+		board.Set(4, 4, &tile.Tile{})
+		NewSolver(&board, &pile, SolverOptions{Rand: rng}).Solve()
+		return board.String()
+	}
+
+	if first, second := solve(42), solve(42); first != second {
+		t.Errorf("Expected the same board for the same seed, got:\n%s\nand:\n%s", first, second)
+	}
+}
+
+func TestSolverDrawPolicies(t *testing.T) {
+	input := `[    ][    ][    ]
+[    ][CCCC][    ]
+[    ][    ][    ]`
+	newPile := func() Pile {
+		return Pile{
+			tile.CreateTile("FFFF"),
+			tile.CreateTile("CFFF"),
+		}
+	}
+
+	t.Run("Top", func(t *testing.T) {
+		board := BoardFromString(input)
+		pile := newPile()
+
+		result := NewSolver(&board, &pile, SolverOptions{DrawPolicy: DrawTop}).Solve()
+		if result.Solved {
+			t.Errorf("Expected FFFF on top of the pile to be unplaceable next to a city, got:\n%s", board.String())
+		}
+	})
+
+	for _, policy := range []DrawPolicy{DrawAny, DrawWeighted} {
+		t.Run(policy.String(), func(t *testing.T) {
+			board := BoardFromString(input)
+			pile := newPile()
+
+			options := SolverOptions{DrawPolicy: policy, Rand: rand.New(rand.NewSource(3))}
+			result := NewSolver(&board, &pile, options).Solve()
+			if !result.Solved {
+				t.Fatalf("Expected the board to be solved, got error: %v", result.Err)
+			}
+			if !strings.Contains(board.String(), "FFFF") {
+				t.Errorf("Expected FFFF to be placed after the city tile, got:\n%s", board.String())
+			}
+		})
+	}
+}
+
+func TestParseDrawPolicy(t *testing.T) {
+	for _, policy := range []DrawPolicy{DrawTop, DrawAny, DrawWeighted} {
+		parsed, err := ParseDrawPolicy(policy.String())
+		if err != nil || parsed != policy {
+			t.Errorf("Expected %s to parse back to itself, got %v (error: %v)", policy, parsed, err)
+		}
+	}
+
+	if _, err := ParseDrawPolicy("bottom"); err == nil {
+		t.Errorf("Expected an error for an unknown draw policy")
+	}
+}
+
+func TestWeightedCandidates(t *testing.T) {
+	pile := Pile{
+		tile.CreateTile("FFFF"),
+		tile.CreateTile("RSRS"),
+		tile.CreateTile("RSRS"),
+		tile.CreateTile("RSRS"),
+		tile.CreateTile("CCCC"),
+	}
+
+	counted := NewCountedPile(&pile)
+	candidates := weightedCandidates(counted, nil)
+	if expected := []tile.Tile{pile[1], pile[0], pile[4]}; !reflect.DeepEqual(candidates, expected) {
+		t.Errorf("Expected one candidate per tile type, most common first, got %v", candidates)
+	}
+
+	first := weightedCandidates(counted, rand.New(rand.NewSource(5)))
+	second := weightedCandidates(counted, rand.New(rand.NewSource(5)))
+	if !reflect.DeepEqual(first, second) || len(first) != 3 {
+		t.Errorf("Expected the same 3 candidates for the same seed, got %v and %v", first, second)
+	}
+}
+
+func TestSolverSkipsIdenticalTiles(t *testing.T) {
+	board := BoardFromString(`[SSSS][    ]`)
+	pile := Pile{
+		tile.CreateTile("RSRS"),
+		tile.CreateTile("SRSR"),
+		tile.CreateTile("RSRS"),
+		tile.CreateTile("FFFF"),
+	}
+
+	solver := NewSolver(&board, &pile, SolverOptions{DrawPolicy: DrawAny})
+	result := solver.Solve()
+
+	if result.Solved {
+		t.Fatalf("Expected 4 tiles not to fit in a single cell")
+	}
+	// Three copies of a tile type that fits in two rotations, but the copies
+	// are identical and so are both rotations
+	if result.Stats.Placements != 1 {
+		t.Errorf("Expected a single placement to be tried, got %d", result.Stats.Placements)
+	}
+	if pile.Size() != 4 || solver.counted.Size() != 4 || solver.counted.Copies(pile[0]) != 3 {
+		t.Errorf("Expected the pile and its counts to be restored, got %d tiles", pile.Size())
+	}
+}
+
+func TestCellFirstCollapsesLowestEntropyCell(t *testing.T) {
+	input := `[    ][    ][    ]
+[    ][CFRF][    ]
+[    ][    ][    ]`
+	pile := Pile{
+		tile.CreateTile("FFFF"),
+		tile.CreateTile("FFFF"),
+		tile.CreateTile("RFRF"),
+		tile.CreateTile("RFRF"),
+		tile.CreateTile("CFFF"),
+	}
+
+	board := BoardFromString(input)
+	expected := getSortedAvailablePositions(&board, &pile, nil)[0]
+
+	var first *SolverEvent
+	solver := NewSolver(&board, &pile, SolverOptions{Strategy: CellFirst, DrawPolicy: DrawAny})
+	solver.Subscribe(func(event SolverEvent) {
+		if first == nil {
+			first = &event
+		}
+	})
+	result := solver.Solve()
+
+	if !result.Solved {
+		t.Fatalf("Expected the board to be solved, got error: %v", result.Err)
+	}
+	if first == nil || first.Row != expected.row || first.Col != expected.col {
+		t.Errorf("Expected the first tile at [%d][%d], got %+v", expected.row, expected.col, first)
+	}
+	if expected.row != 0 || expected.col != 1 {
+		t.Errorf("Expected the city side above the start tile to be the most constrained cell, got [%d][%d]",
+			expected.row, expected.col)
+	}
+}
+
+func TestCellFirstCountsDistinctTilesNotCopies(t *testing.T) {
+	for _, propagate := range []bool{false, true} {
+		board := BoardFromString("[    ][FCFS][    ]")
+		pile := Pile{tile.CreateTile("SSSS"), tile.CreateTile("FSFS")}
+		for range 10 {
+			pile = append(pile, tile.CreateTile("CCCC"))
+		}
+
+		var first *SolverEvent
+		solver := NewSolver(&board, &pile, SolverOptions{Strategy: CellFirst, DrawPolicy: DrawAny, Propagate: propagate})
+		solver.Subscribe(func(event SolverEvent) {
+			if first == nil {
+				first = &event
+			}
+		})
+		solver.Solve()
+
+		if first == nil || first.Row != 0 || first.Col != 2 {
+			t.Errorf("Expected the cell only CCCC fits to be collapsed first with propagation %v, got %+v", propagate, first)
+		}
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	for _, strategy := range []Strategy{TileFirst, CellFirst} {
+		parsed, err := ParseStrategy(strategy.String())
+		if err != nil || parsed != strategy {
+			t.Errorf("Expected %s to parse back to itself, got %v (error: %v)", strategy, parsed, err)
+		}
+	}
+
+	if _, err := ParseStrategy("random"); err == nil {
+		t.Errorf("Expected an error for an unknown strategy")
+	}
+}
+
+// BenchmarkSolve solves the default tiles on an unbounded board, the hot path
+// of which is matching pile tiles against the patterns of the open cells
+func BenchmarkSolve(b *testing.B) {
+	tiles, err := loadTilesFromFile("tiles.txt")
+	if err != nil {
+		b.Fatal(err)
+	}
+	tiles.Shuffle(rand.New(rand.NewSource(1)))
+
+	for b.Loop() {
+		pile := tiles.Clone()
+		board := NewUnboundedBoard()
+		start := pile.PopTop()
+		board.Pin(0, 0, &start)
+		if result := NewSolver(&board, &pile, SolverOptions{Propagate: true}).Solve(); !result.Solved {
+			b.Fatalf("Expected the tiles to be placed, got error: %v", result.Err)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package tile
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+)
+
+// Border is a kind of border a tile side can show, as an index into the
+// registered border types
+type Border int
+
+// The border types of the default registry
+const (
+	Field Border = iota
+	City
+	Stream
+	Road
+)
+
+// Role is the part a border type plays in the rules of Carcassonne, which
+// decides how the sides of a tile without a layout join into features and
+// how the features score. Border types with no role, such as lakes, score
+// nothing.
+type Role int
+
+const (
+	NoRole Role = iota
+	FieldRole
+	CityRole
+	RoadRole
+	StreamRole
+)
+
+var roleNames = []string{"none", "field", "city", "road", "stream"}
+
+func (r Role) String() string {
+	if r < 0 || int(r) >= len(roleNames) {
+		panic("Unknown border role")
+	}
+	return roleNames[r]
+}
+
+func ParseRole(name string) (Role, error) {
+	for i, roleName := range roleNames {
+		if roleName == name {
+			return Role(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown border role %q, expected one of %v", name, roleNames)
+}
+
+// Path reports whether features of the role run across a tile as a line,
+// dividing the fields on either side of them
+func (r Role) Path() bool {
+	return r == RoadRole || r == StreamRole
+}
+
+// BorderType describes a kind of border: the letter it is written as in tile
+// patterns, its name, the colour it is drawn in, its role in the rules and
+// the borders it may be placed against
+type BorderType struct {
+	Char  byte
+	Name  string
+	Color color.RGBA
+	Role  Role
+
+	// Touches holds the letters of the other border types this one may be
+	// placed against, e.g. "L" for a stream that may flow into a lake. The
+	// relation goes both ways, so the lake does not need to list the stream.
+	Touches string
+
+	// Socket border types do not touch themselves, only the ones in Touches,
+	// like the male and female halves of a connector
+	Socket bool
+}
+
+// maxBorderTypes is the number of border types that fit the bits of a segment
+const maxBorderTypes = 1 << bitsPerSegment
+
+// reservedBorderChars cannot be used as border letters as they mean something
+// else in patterns and board drawings
+const reservedBorderChars = "?#[], "
+
+// DefaultBorderTypes are the border types of Carcassonne
+var DefaultBorderTypes = []BorderType{
+	{Char: 'F', Name: "field", Color: color.RGBA{34, 139, 34, 255}, Role: FieldRole},    // Forest green
+	{Char: 'C', Name: "city", Color: color.RGBA{139, 69, 19, 255}, Role: CityRole},      // Brown
+	{Char: 'S', Name: "stream", Color: color.RGBA{30, 144, 255, 255}, Role: StreamRole}, // Dodger blue
+	{Char: 'R', Name: "road", Color: color.RGBA{128, 128, 128, 255}, Role: RoadRole},    // Gray
+}
+
+var (
+	borderTypes = DefaultBorderTypes
+	borderIndex = indexBorderTypes(DefaultBorderTypes)
+
+	// touching holds, for every border, the set of borders it may touch
+	touching, exactTouching = buildTouching(DefaultBorderTypes, borderIndex)
+)
+
+func indexBorderTypes(types []BorderType) map[byte]Border {
+	index := make(map[byte]Border, len(types))
+	for i, borderType := range types {
+		index[borderType.Char] = Border(i)
+	}
+	return index
+}
+
+// buildTouching builds the compatibility matrix of the border types, and
+// reports whether every border only touches itself
+func buildTouching(types []BorderType, index map[byte]Border) ([maxBorderTypes]uint16, bool) {
+	var touching [maxBorderTypes]uint16
+	for i, borderType := range types {
+		if !borderType.Socket {
+			touching[i] |= 1 << i
+		}
+		for j := range len(borderType.Touches) {
+			other := index[borderType.Touches[j]]
+			touching[i] |= 1 << other
+			touching[other] |= 1 << i
+		}
+	}
+
+	exact := true
+	for i := range types {
+		exact = exact && touching[i] == 1<<i
+	}
+	return touching, exact
+}
+
+// SetBorderTypes replaces the registered border types. Tiles and patterns
+// read before the change keep their border indexes, so this should be done
+// before any tile is created.
+func SetBorderTypes(types []BorderType) error {
+	if len(types) == 0 || len(types) > maxBorderTypes {
+		return fmt.Errorf("expected 1 to %d border types, got %d", maxBorderTypes, len(types))
+	}
+	index := indexBorderTypes(types)
+	for i, borderType := range types {
+		if borderType.Char <= ' ' || borderType.Char > '~' || strings.IndexByte(reservedBorderChars, borderType.Char) >= 0 {
+			return fmt.Errorf("border type %q cannot be written as %q", borderType.Name, borderType.Char)
+		}
+		if index[borderType.Char] != Border(i) {
+			return fmt.Errorf("border letter %q is used more than once", borderType.Char)
+		}
+		for j := range len(borderType.Touches) {
+			if _, ok := index[borderType.Touches[j]]; !ok {
+				return fmt.Errorf("border type %q touches unknown border %q", borderType.Name, borderType.Touches[j])
+			}
+		}
+	}
+
+	borderTypes = append([]BorderType(nil), types...)
+	borderIndex = index
+	touching, exactTouching = buildTouching(types, index)
+	return nil
+}
+
+// BorderTypes returns the registered border types, indexed by Border
+func BorderTypes() []BorderType {
+	return append([]BorderType(nil), borderTypes...)
+}
+
+// BorderCount returns the number of registered border types
+func BorderCount() int {
+	return len(borderTypes)
+}
+
+func (b Border) String() string {
+	return string(b.Type().Char)
+}
+
+// Role returns the role of the registered type of the border
+func (b Border) Role() Role {
+	return b.Type().Role
+}
+
+// Type returns the registered type of the border
+func (b Border) Type() BorderType {
+	if b < 0 || int(b) >= len(borderTypes) {
+		panic("Unknown border type")
+	}
+	return borderTypes[b]
+}
+
+// CanTouch reports whether a tile side showing a may be placed against a
+// side showing b
+func CanTouch(a, b Border) bool {
+	return touching[a]&(1<<b) != 0
+}
+
+// ExactTouching reports whether every registered border only touches itself,
+// so that a tile fits a pattern exactly when it shows the borders required
+func ExactTouching() bool {
+	return exactTouching
+}
+
+// Touching returns every border the border may be placed against
+func (b Border) Touching() []Border {
+	var borders []Border
+	for other := range Border(len(borderTypes)) {
+		if CanTouch(b, other) {
+			borders = append(borders, other)
+		}
+	}
+	return borders
+}
+
+// ParseBorder reads a single border letter such as "F"
+func ParseBorder(s string) (Border, error) {
+	if len(s) != 1 {
+		return 0, fmt.Errorf("invalid border %q, expected a single letter", s)
+	}
+	return parseBorder(s[0])
+}
+
+func parseBorder(b byte) (Border, error) {
+	if border, ok := borderIndex[b]; ok {
+		return border, nil
+	}
+	return 0, fmt.Errorf("unknown border type %q", b)
+}
+
+// ParseBorderTypes reads border types, one per line in the form
+//
+//	<letter> <name> <#rrggbb colour> [<letters of the borders it touches>] [socket] [role=<role>]
+//
+// A border type without a role takes the one its name stands for, if any,
+// so that "F field" is a field wherever it is listed. Blank lines and lines
+// starting with # are skipped.
+func ParseBorderTypes(r io.Reader) ([]BorderType, error) {
+	var types []BorderType
+	var err error
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		role, roleSet := NoRole, false
+		if len(fields) > 3 {
+			if name, ok := strings.CutPrefix(fields[len(fields)-1], "role="); ok {
+				if role, err = ParseRole(name); err != nil {
+					return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+				}
+				roleSet = true
+				fields = fields[:len(fields)-1]
+			}
+		}
+		socket := len(fields) > 3 && fields[len(fields)-1] == "socket"
+		if socket {
+			fields = fields[:len(fields)-1]
+		}
+		if len(fields) < 3 || len(fields) > 4 {
+			return nil, fmt.Errorf("line %d: expected a letter, a name, a colour and optionally the borders it touches, got %q", lineNumber, line)
+		}
+		if len(fields[0]) != 1 {
+			return nil, fmt.Errorf("line %d: invalid border letter %q", lineNumber, fields[0])
+		}
+		borderColor, err := parseColor(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		if !roleSet {
+			role, _ = ParseRole(fields[1])
+		}
+		borderType := BorderType{Char: fields[0][0], Name: fields[1], Color: borderColor, Role: role, Socket: socket}
+		if len(fields) == 4 {
+			borderType.Touches = fields[3]
+		}
+		types = append(types, borderType)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return types, nil
+}
+
+// parseColor reads a colour in the #rrggbb form
+func parseColor(s string) (color.RGBA, error) {
+	var r, g, b uint8
+	if len(s) != 7 || s[0] != '#' {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q, expected #rrggbb", s)
+	}
+	if _, err := fmt.Sscanf(s[1:], "%02x%02x%02x", &r, &g, &b); err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q, expected #rrggbb", s)
+	}
+	return color.RGBA{r, g, b, 255}, nil
+}
@@ -0,0 +1,486 @@
+package tile
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Slot is a segment of a side, the smallest part of the edge of a tile that a
+// feature can reach
+type Slot struct {
+	Side    Side
+	Segment Segment
+}
+
+// Feature is a connected part of a tile, such as a city spread over two sides
+// or a road running from one side to another. A road or stream that reaches
+// a single side ends on the tile, e.g. at a crossing or a monastery.
+type Feature struct {
+	Border Border // kind of the feature, shown by all of its slots
+	Slots  []Slot
+
+	// Pennant marks a city that is worth more, see the scoring package
+	Pennant bool
+}
+
+// Ends reports whether the feature reaches a single side, so that a road or
+// stream comes to an end on the tile
+func (f Feature) Ends() bool {
+	for _, slot := range f.Slots {
+		if slot.Side != f.Slots[0].Side {
+			return false
+		}
+	}
+	return true
+}
+
+// Layout describes the inside of a tile: which slots belong to the same
+// feature, and whether it has a monastery. Every slot of the tile belongs to
+// exactly one feature.
+type Layout struct {
+	Features  []Feature
+	Monastery bool
+}
+
+// layoutBits is the number of bits of a Code for the layout id
+const layoutBits = 16
+
+var (
+	// layouts holds every layout in use, in the frame of the tile turned so
+	// that its borders and layout are smallest. Id 0 is the default layout,
+	// see defaultFeatures.
+	layouts     = []Layout{{}}
+	layoutIndex = map[string]int{}
+)
+
+// Features returns the features of the tile as it is turned now. Tiles
+// without a layout connect all their city slots, all their field slots and
+// roads or streams that reach exactly two sides; any other road or stream
+// ends on the tile.
+func (t *Tile) Features() []Feature {
+	id := int(t.code.layout)
+	if id == 0 {
+		return defaultFeatures(t)
+	}
+
+	features := make([]Feature, len(layouts[id].Features))
+	for i, feature := range layouts[id].Features {
+		features[i] = Feature{Border: feature.Border, Slots: make([]Slot, len(feature.Slots)), Pennant: feature.Pennant}
+		for j, slot := range feature.Slots {
+			features[i].Slots[j] = Slot{Side: (slot.Side + Side(t.code.turns)) % Side(t.Sides()), Segment: slot.Segment}
+		}
+	}
+	// In side order as the tile is turned now, not as the layout is stored
+	return sortedFeatures(features)
+}
+
+// Monastery reports whether the tile has a monastery in its middle
+func (t *Tile) Monastery() bool {
+	return layouts[t.code.layout].Monastery
+}
+
+// WithLayout returns the tile with the given layout, in the frame of the tile
+// as it is turned now
+func (t *Tile) WithLayout(layout Layout) (Tile, error) {
+	covered := make(map[Slot]bool)
+	for _, feature := range layout.Features {
+		if len(feature.Slots) == 0 {
+			return Tile{}, fmt.Errorf("a feature needs at least one slot")
+		}
+		for _, slot := range feature.Slots {
+			if slot.Side < 0 || int(slot.Side) >= t.Sides() || slot.Segment < 0 || slot.Segment >= SegmentCount {
+				return Tile{}, fmt.Errorf("slot %v is not on a tile with %d sides", slot, t.Sides())
+			}
+			if covered[slot] {
+				return Tile{}, fmt.Errorf("side %s is in more than one feature", formatSlots(t, []Slot{slot}))
+			}
+			if border := t.code.segment(slot.Side, slot.Segment); border != feature.Border {
+				return Tile{}, fmt.Errorf("side %s shows %s, not %s", formatSlots(t, []Slot{slot}), border, feature.Border)
+			}
+			covered[slot] = true
+		}
+	}
+	if len(covered) != t.Sides()*int(SegmentCount) {
+		return Tile{}, fmt.Errorf("every side must be in a feature")
+	}
+
+	// Store the layout turned the way that gives the smallest borders and
+	// layout, so that the same tile shares an id however it is turned
+	var stored Layout
+	var key string
+	var turns int
+	var borders Code
+	for rotation := range t.Sides() {
+		rotated := t.Rotate(-rotation)
+		candidate := turnLayout(layout, -rotation, t.Sides())
+		candidateKey := fmt.Sprintf("%d %v", t.Sides(), candidate)
+		order := slices.Compare(rotated.code.sides[:], borders.sides[:])
+		if rotation == 0 || order < 0 || order == 0 && candidateKey < key {
+			stored, key, turns, borders = candidate, candidateKey, rotation, rotated.code
+		}
+	}
+
+	id, ok := layoutIndex[key]
+	if !ok {
+		if len(layouts) == 1<<layoutBits {
+			return Tile{}, fmt.Errorf("too many different tile layouts, at most %d are supported", 1<<layoutBits-1)
+		}
+		id = len(layouts)
+		layouts = append(layouts, stored)
+		layoutIndex[key] = id
+	}
+
+	withLayout := *t
+	withLayout.code.layout = uint16(id)
+	withLayout.code.turns = uint8(turns)
+	return withLayout, nil
+}
+
+// turnLayout returns a copy of the layout with every slot turned clockwise by
+// the given number of turns, in a canonical order so equal layouts compare
+// equal
+func turnLayout(layout Layout, turns, sides int) Layout {
+	turned := Layout{Monastery: layout.Monastery}
+	for _, feature := range layout.Features {
+		var slots []Slot
+		for _, slot := range feature.Slots {
+			slots = append(slots, Slot{Side: Side(((int(slot.Side)+turns)%sides + sides) % sides), Segment: slot.Segment})
+		}
+		turned.Features = append(turned.Features, Feature{Border: feature.Border, Slots: slots, Pennant: feature.Pennant})
+	}
+	sortedFeatures(turned.Features)
+	return turned
+}
+
+// sameFeatures reports whether two tiles with the same borders have the same
+// features, which is always the case when neither has a layout of its own
+func (t *Tile) sameFeatures(other *Tile) bool {
+	if t.code.layout == 0 && other.code.layout == 0 {
+		return true
+	}
+	a, b := sortedFeatures(t.Features()), sortedFeatures(other.Features())
+	return fmt.Sprint(a) == fmt.Sprint(b) && t.Monastery() == other.Monastery()
+}
+
+// sortedFeatures puts the slots of every feature and the features themselves
+// in side order
+func sortedFeatures(features []Feature) []Feature {
+	for _, feature := range features {
+		sortSlots(feature.Slots)
+	}
+	slices.SortFunc(features, func(a, b Feature) int {
+		return compareSlots(a.Slots[0], b.Slots[0])
+	})
+	return features
+}
+
+func compareSlots(a, b Slot) int {
+	if a.Side != b.Side {
+		return int(a.Side - b.Side)
+	}
+	return int(a.Segment - b.Segment)
+}
+
+func sortSlots(slots []Slot) {
+	slices.SortFunc(slots, compareSlots)
+}
+
+// defaultFeatures returns the features of a tile without a layout
+func defaultFeatures(t *Tile) []Feature {
+	var borders []Border
+	slotsByBorder := make(map[Border][]Slot)
+	for side := range Side(t.Sides()) {
+		for segment := range SegmentCount {
+			border := t.code.segment(side, segment)
+			if _, ok := slotsByBorder[border]; !ok {
+				borders = append(borders, border)
+			}
+			slotsByBorder[border] = append(slotsByBorder[border], Slot{side, segment})
+		}
+	}
+
+	// Cities come first, so that a road or stream that would cross one ends
+	// at it instead
+	byBorder := make(map[Border][]Feature)
+	var others []Feature
+	var cities [][]Slot
+	for _, border := range borders {
+		if border.Role() == CityRole {
+			byBorder[border] = []Feature{{Border: border, Slots: slotsByBorder[border]}}
+			others = append(others, byBorder[border]...)
+			cities = append(cities, slotsByBorder[border])
+		}
+	}
+	for _, border := range borders {
+		if role := border.Role(); role == CityRole || role == FieldRole {
+			continue
+		}
+		slots := slotsByBorder[border]
+		var sides [][]Slot
+		for _, slot := range slots {
+			if len(sides) == 0 || sides[len(sides)-1][0].Side != slot.Side {
+				sides = append(sides, nil)
+			}
+			sides[len(sides)-1] = append(sides[len(sides)-1], slot)
+		}
+
+		if len(sides) == 2 && !separated(t, cities, sides[0][0], sides[1][0]) {
+			byBorder[border] = []Feature{{Border: border, Slots: slots}}
+		} else {
+			for _, sideSlots := range sides {
+				byBorder[border] = append(byBorder[border], Feature{Border: border, Slots: sideSlots})
+			}
+		}
+		others = append(others, byBorder[border]...)
+	}
+
+	var features []Feature
+	for _, border := range borders {
+		if border.Role() != FieldRole {
+			features = append(features, byBorder[border]...)
+			continue
+		}
+		for _, field := range fieldAreas(t, slotsByBorder[border], others) {
+			features = append(features, Feature{Border: border, Slots: field})
+		}
+	}
+	return features
+}
+
+// fieldAreas splits the field slots of a tile into the fields that the other
+// features divide them into: two field slots are in the same field unless a
+// feature reaches the edge of the tile both ways round from one to the other.
+// Roads and streams that end on the tile meet in its middle, together with
+// the features that run through it, so they divide the fields as one.
+func fieldAreas(t *Tile, fields []Slot, features []Feature) [][]Slot {
+	var barriers [][]Slot
+	var middle []Slot
+	for _, feature := range features {
+		if feature.Border.Role() == FieldRole {
+			continue
+		}
+		barriers = append(barriers, feature.Slots)
+		if feature.Border.Role().Path() && feature.Ends() || t.runsThrough(feature.Slots) {
+			middle = append(middle, feature.Slots...)
+		}
+	}
+	barriers = append(barriers, middle)
+
+	var areas [][]Slot
+	area := make([]int, len(fields))
+	for i, slot := range fields {
+		area[i] = len(areas)
+		for j := range i {
+			if !separated(t, barriers, fields[j], slot) {
+				area[i] = area[j]
+				break
+			}
+		}
+		if area[i] == len(areas) {
+			areas = append(areas, nil)
+		}
+		areas[area[i]] = append(areas[area[i]], slot)
+	}
+	return areas
+}
+
+// separated reports whether one of the barriers reaches the edge of the tile
+// both ways round from one slot to the other
+func separated(t *Tile, barriers [][]Slot, a, b Slot) bool {
+	slots := t.Sides() * int(SegmentCount)
+	from, to := slotIndex(a), slotIndex(b)
+	for _, barrier := range barriers {
+		var between, outside bool
+		for _, slot := range barrier {
+			if (slotIndex(slot)-from+slots)%slots < (to-from+slots)%slots {
+				between = true
+			} else {
+				outside = true
+			}
+		}
+		if between && outside {
+			return true
+		}
+	}
+	return false
+}
+
+// runsThrough reports whether the slots of a feature reach the edge of the
+// tile in more than one place, so that it crosses the tile
+func (t *Tile) runsThrough(feature []Slot) bool {
+	slots := t.Sides() * int(SegmentCount)
+	reaches := make([]bool, slots)
+	for _, slot := range feature {
+		reaches[slotIndex(slot)] = true
+	}
+	places := 0
+	for i := range slots {
+		if reaches[i] && !reaches[(i+slots-1)%slots] {
+			places++
+		}
+	}
+	return places > 1
+}
+
+// slotIndex returns the position of a slot round the edge of the tile,
+// counting clockwise from the first segment of the first side
+func slotIndex(slot Slot) int {
+	return int(slot.Side)*int(SegmentCount) + int(slot.Segment)
+}
+
+// sideLetters names the sides of a square tile in layouts
+const sideLetters = "TRBL"
+
+// segmentLetters names the segments of a side in layouts
+const segmentLetters = "lcr"
+
+// ParseLayout reads the features of a tile, separated by spaces. A feature is
+// written as the sides it reaches, T, R, B and L for a square tile or 0 to 5
+// clockwise from the top right for a hexagonal one, e.g. TR for a city over
+// the top and right sides. A side with segments needs the segment too: l, c
+// or r, e.g. TcBc for a road through the centre of the top and bottom sides.
+// A feature ending in + has a pennant, e.g. TR+. M puts a monastery in the
+// middle of the tile.
+//
+// Sides that are in no feature keep the default layout: their field segments
+// form the fields that the other features divide them into and every other
+// side is a feature of its own, so a road on such a side ends on the tile.
+func ParseLayout(t *Tile, s string) (Layout, error) {
+	var layout Layout
+	covered := make(map[Slot]bool)
+	for _, token := range strings.Fields(s) {
+		if token == "M" {
+			layout.Monastery = true
+			continue
+		}
+
+		feature := Feature{Border: -1}
+		if sides, ok := strings.CutSuffix(token, "+"); ok {
+			feature.Pennant = true
+			token = sides
+		}
+		if token == "" {
+			return Layout{}, fmt.Errorf("a pennant needs the sides of its feature, e.g. TR+")
+		}
+		for i := 0; i < len(token); i++ {
+			side := Side(strings.IndexByte(sideLetters, token[i]))
+			if t.Sides() != int(SideCount) {
+				side = Side(token[i]) - '0'
+			}
+			if side < 0 || int(side) >= t.Sides() {
+				return Layout{}, fmt.Errorf("unknown side %q in feature %q", token[i], token)
+			}
+
+			slots := []Slot{{side, SegmentLeft}, {side, SegmentCentre}, {side, SegmentRight}}
+			if i+1 < len(token) && strings.IndexByte(segmentLetters, token[i+1]) >= 0 {
+				slots = []Slot{{side, Segment(strings.IndexByte(segmentLetters, token[i+1]))}}
+				i++
+			} else if _, uniform := t.Segments(side).Uniform(); !uniform {
+				return Layout{}, fmt.Errorf("side %q has segments, name one of them in feature %q, e.g. %c%c", token[i], token, token[i], segmentLetters[SegmentCentre])
+			}
+
+			for _, slot := range slots {
+				border := t.code.segment(slot.Side, slot.Segment)
+				if feature.Border >= 0 && border != feature.Border {
+					return Layout{}, fmt.Errorf("feature %q joins %s and %s", token, feature.Border, border)
+				}
+				if covered[slot] {
+					return Layout{}, fmt.Errorf("feature %q reaches a side that is already in another feature", token)
+				}
+				feature.Border = border
+				covered[slot] = true
+				feature.Slots = append(feature.Slots, slot)
+			}
+		}
+		layout.Features = append(layout.Features, feature)
+	}
+
+	var fields []Slot
+	for side := range Side(t.Sides()) {
+		var sideSlots []Slot
+		for segment := range SegmentCount {
+			slot := Slot{side, segment}
+			switch {
+			case covered[slot]:
+			case t.code.segment(side, segment).Role() == FieldRole:
+				fields = append(fields, slot)
+			default:
+				sideSlots = append(sideSlots, slot)
+			}
+		}
+		// Segments of a side that show different borders are different
+		// features
+		for len(sideSlots) > 0 {
+			border := t.code.segment(side, sideSlots[0].Segment)
+			feature := Feature{Border: border}
+			var rest []Slot
+			for _, slot := range sideSlots {
+				if t.code.segment(side, slot.Segment) == border {
+					feature.Slots = append(feature.Slots, slot)
+				} else {
+					rest = append(rest, slot)
+				}
+			}
+			layout.Features = append(layout.Features, feature)
+			sideSlots = rest
+		}
+	}
+	// Fields of different border types are different features
+	for len(fields) > 0 {
+		border := t.code.segment(fields[0].Side, fields[0].Segment)
+		var same, rest []Slot
+		for _, slot := range fields {
+			if t.code.segment(slot.Side, slot.Segment) == border {
+				same = append(same, slot)
+			} else {
+				rest = append(rest, slot)
+			}
+		}
+		for _, field := range fieldAreas(t, same, layout.Features) {
+			layout.Features = append(layout.Features, Feature{Border: border, Slots: field})
+		}
+		fields = rest
+	}
+	return layout, nil
+}
+
+// formatSlots writes slots the way ParseLayout reads them
+func formatSlots(t *Tile, slots []Slot) string {
+	var sb strings.Builder
+	for i := 0; i < len(slots); i++ {
+		slot := slots[i]
+		if t.Sides() == int(SideCount) {
+			sb.WriteByte(sideLetters[slot.Side])
+		} else {
+			sb.WriteByte('0' + byte(slot.Side))
+		}
+
+		// A whole side is written without its segments
+		if slot.Segment == SegmentLeft && i+2 < len(slots) && slots[i+2] == (Slot{slot.Side, SegmentRight}) {
+			i += 2
+			continue
+		}
+		sb.WriteByte(segmentLetters[slot.Segment])
+	}
+	return sb.String()
+}
+
+// Describe writes the tile in the format of ParseTile, with its features
+// unless it has the default layout
+func (t *Tile) Describe() string {
+	if t.code.layout == 0 {
+		return t.String()
+	}
+	description := t.String()
+	for _, feature := range sortedFeatures(t.Features()) {
+		description += " " + formatSlots(t, feature.Slots)
+		if feature.Pennant {
+			description += "+"
+		}
+	}
+	if t.Monastery() {
+		description += " M"
+	}
+	return description
+}
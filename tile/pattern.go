@@ -0,0 +1,94 @@
+package tile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pattern is the packed form of a query such as "C??F": Value holds the
+// borders of the neighbouring sides and Mask has all bits set for every
+// segment that is not a wildcard. A tile matches when each of its
+// constrained segments can touch the border in Value, which as long as every
+// border only touches itself means its code masked by Mask equals Value.
+type Pattern struct {
+	Value Code
+	Mask  Code
+}
+
+// With returns the pattern with the whole side required to be border
+func (p Pattern) With(side Side, border Border) Pattern {
+	return p.WithSegments(side, UniformSegments(border))
+}
+
+// WithSegments returns the pattern with every segment of the side required
+// to be the given border
+func (p Pattern) WithSegments(side Side, segments Segments) Pattern {
+	p.Value = p.Value.withSegments(side, segments)
+	p.Mask.sides[side] = sideMask
+	return p
+}
+
+// withSegment returns the pattern with a single segment required to be border
+func (p Pattern) withSegment(side Side, segment Segment, border Border) Pattern {
+	segments := p.Value.segments(side)
+	segments[segment] = border
+	p.Value = p.Value.withSegments(side, segments)
+	p.Mask.sides[side] |= segmentMask << (bitsPerSegment * int(segment))
+	return p
+}
+
+// Requires reports whether a segment is constrained, and to which border
+func (p Pattern) Requires(side Side, segment Segment) (Border, bool) {
+	if p.Mask.segment(side, segment) == 0 {
+		return 0, false
+	}
+	return p.Value.segment(side, segment), true
+}
+
+func (p Pattern) String() string {
+	return p.Format(int(SideCount))
+}
+
+// Format writes the pattern for a tile with the given number of sides
+func (p Pattern) Format(sides int) string {
+	var sb strings.Builder
+	for side := range Side(sides) {
+		var letters [SegmentCount]string
+		for segment := range SegmentCount {
+			letters[segment] = "?"
+			if border, ok := p.Requires(side, segment); ok {
+				letters[segment] = border.String()
+			}
+		}
+		if letters[SegmentLeft] == letters[SegmentCentre] && letters[SegmentCentre] == letters[SegmentRight] {
+			sb.WriteString(letters[SegmentCentre])
+		} else {
+			sb.WriteString("(" + strings.Join(letters[:], "") + ")")
+		}
+	}
+	return sb.String()
+}
+
+// ParsePattern reads a query in the format of ParseTile, where ? matches any
+// border, e.g. C??F or (?R?)???
+func ParsePattern(query string) (Pattern, error) {
+	sides, err := splitSides(query)
+	if err != nil {
+		return Pattern{}, fmt.Errorf("invalid query %q: %w", query, err)
+	}
+	var pattern Pattern
+	for side, letters := range sides {
+		for segment := range SegmentCount {
+			letter := letters[int(segment)%len(letters)]
+			if letter == '?' {
+				continue
+			}
+			border, err := parseBorder(letter)
+			if err != nil {
+				return Pattern{}, fmt.Errorf("invalid query %q: %w", query, err)
+			}
+			pattern = pattern.withSegment(Side(side), segment, border)
+		}
+	}
+	return pattern, nil
+}
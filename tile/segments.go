@@ -0,0 +1,80 @@
+package tile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Segment identifies a third of a side, in clockwise order round the tile:
+// seen from the middle of the tile, the left third comes first
+type Segment int
+
+const (
+	SegmentLeft Segment = iota
+	SegmentCentre
+	SegmentRight
+	SegmentCount
+)
+
+// Segments holds the borders of the three segments of a side, such as a road
+// running through the middle of a field
+type Segments [SegmentCount]Border
+
+// UniformSegments returns a side showing a single border all along it
+func UniformSegments(border Border) Segments {
+	return Segments{border, border, border}
+}
+
+// Uniform reports whether every segment shows the same border, and which
+func (s Segments) Uniform() (Border, bool) {
+	return s[SegmentLeft], s[SegmentLeft] == s[SegmentCentre] && s[SegmentCentre] == s[SegmentRight]
+}
+
+// Mirror returns the segments in the order the tile across the side sees
+// them: its left segment touches this side's right one
+func (s Segments) Mirror() Segments {
+	return Segments{s[SegmentRight], s[SegmentCentre], s[SegmentLeft]}
+}
+
+// CanTouch reports whether the side may be placed against the side of the
+// tile across it, comparing the segments in mirrored order
+func (s Segments) CanTouch(other Segments) bool {
+	mirrored := other.Mirror()
+	for segment := range SegmentCount {
+		if !CanTouch(s[segment], mirrored[segment]) {
+			return false
+		}
+	}
+	return true
+}
+
+// String writes a uniform side as its single border letter and any other
+// side as its three letters in parentheses, e.g. (FRF)
+func (s Segments) String() string {
+	if border, ok := s.Uniform(); ok {
+		return border.String()
+	}
+	return "(" + s[SegmentLeft].String() + s[SegmentCentre].String() + s[SegmentRight].String() + ")"
+}
+
+// splitSides cuts a tile or pattern string into one part per side: a single
+// letter, or the three letters of a side with segments written in parentheses
+func splitSides(s string) ([]string, error) {
+	var sides []string
+	for i := 0; i < len(s); i++ {
+		if s[i] != '(' {
+			sides = append(sides, s[i:i+1])
+			continue
+		}
+		end := strings.IndexByte(s[i:], ')')
+		if end != int(SegmentCount)+1 {
+			return nil, fmt.Errorf("expected three segments like (FRF) at position %d", i)
+		}
+		sides = append(sides, s[i+1:i+end])
+		i += end
+	}
+	if len(sides) != int(SideCount) && len(sides) != int(HexSideCount) {
+		return nil, fmt.Errorf("%d sides, expected %d or %d", len(sides), SideCount, HexSideCount)
+	}
+	return sides, nil
+}
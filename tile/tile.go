@@ -1,103 +1,335 @@
 package tile
 
-import "math/rand"
+import (
+	"cmp"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+)
+
+// Side identifies one edge of a tile, in clockwise order starting at the top.
+// Hexagonal tiles have HexSideCount sides, starting at the top right.
+type Side int
+
+const (
+	SideTop Side = iota
+	SideRight
+	SideBottom
+	SideLeft
+	SideCount
+)
+
+// HexSideCount is the number of sides of a hexagonal tile
+const HexSideCount Side = 6
+
+// Opposite returns the side of a neighbouring tile that touches this side
+func (s Side) Opposite() Side {
+	return s.OppositeIn(int(SideCount))
+}
+
+// OppositeIn returns the side of a neighbouring tile that touches this side
+// on a grid of tiles with the given number of sides
+func (s Side) OppositeIn(sides int) Side {
+	return (s + Side(sides/2)) % Side(sides)
+}
 
-type Border int
+// Code packs the borders of a tile into bitsPerSegment bits per segment, one
+// word per side with the first segment in the lowest bits, so that tiles can
+// be compared and matched with integer operations. It also identifies the
+// layout of the tile, see Layout.
+type Code struct {
+	sides  [HexSideCount]uint16
+	layout uint16
+	turns  uint8 // of the tile from the frame its layout is stored in
+}
 
 const (
-	Field Border = iota
-	City
-	Stream
-	Road
-	BorderLength
+	// bitsPerSegment allows for 16 border types, and the three segments of
+	// a side fit its word
+	bitsPerSegment        = 4
+	segmentMask    uint16 = 1<<bitsPerSegment - 1
+	sideMask       uint16 = 1<<(bitsPerSegment*int(SegmentCount)) - 1
 )
 
-func (b Border) String() string {
-	switch b {
-	case Field:
-		return "F"
-	case City:
-		return "C"
-	case Stream:
-		return "S"
-	case Road:
-		return "R"
-	default:
-		panic("Unknown border type")
+func (c Code) segment(side Side, segment Segment) Border {
+	return Border(c.sides[side] >> (bitsPerSegment * int(segment)) & segmentMask)
+}
+
+func (c Code) segments(side Side) Segments {
+	var segments Segments
+	for segment := range SegmentCount {
+		segments[segment] = c.segment(side, segment)
+	}
+	return segments
+}
+
+func (c Code) withSegments(side Side, segments Segments) Code {
+	var word uint16
+	for segment, border := range segments {
+		word |= uint16(border) << (bitsPerSegment * segment)
+	}
+	c.sides[side] = word
+	return c
+}
+
+// masked returns the borders of the code where the mask has bits set,
+// without the layout
+func (c Code) masked(mask Code) Code {
+	var masked Code
+	for side := range c.sides {
+		masked.sides[side] = c.sides[side] & mask.sides[side]
+	}
+	return masked
+}
+
+// compare orders codes by layout, then by the borders of every side and then
+// by the turns of the layout
+func (c Code) compare(other Code) int {
+	if c.layout != other.layout {
+		return cmp.Compare(c.layout, other.layout)
+	}
+	if order := slices.Compare(c.sides[:], other.sides[:]); order != 0 {
+		return order
 	}
+	return cmp.Compare(c.turns, other.turns)
 }
 
 type Tile struct {
-	top    Border
-	right  Border
-	bottom Border
-	left   Border
+	code Code
+
+	// rotation is the number of clockwise turns by one side applied to the
+	// tile as it was created.
+	rotation int
+
+	// sides is the number of sides of the tile, 0 for the usual four
+	sides uint8
+}
+
+// Sides returns the number of sides of the tile
+func (t *Tile) Sides() int {
+	if t.sides == 0 {
+		return int(SideCount)
+	}
+	return int(t.sides)
 }
 
 func (t *Tile) Top() string {
-	return t.top.String()
+	return t.Segments(SideTop).String()
 }
 func (t *Tile) Right() string {
-	return t.right.String()
+	return t.Segments(SideRight).String()
 }
 func (t *Tile) Bottom() string {
-	return t.bottom.String()
+	return t.Segments(SideBottom).String()
 }
 func (t *Tile) Left() string {
-	return t.left.String()
+	return t.Segments(SideLeft).String()
+}
+
+// Side returns the border in the middle of a side, which is the border of
+// the whole side unless it has segments
+func (t *Tile) Side(side Side) Border {
+	return t.code.segment(side, SegmentCentre)
+}
+
+// Segments returns the borders of the three segments of a side
+func (t *Tile) Segments(side Side) Segments {
+	return t.code.segments(side)
 }
 
+// Code returns the packed borders and layout of the tile
+func (t *Tile) Code() Code {
+	return t.code
+}
+
+// String writes the borders of the tile, without its layout
 func (t *Tile) String() string {
-	return t.Top() + t.Right() + t.Bottom() + t.Left()
+	var s string
+	for side := range Side(t.Sides()) {
+		s += t.Segments(side).String()
+	}
+	return s
+}
+
+// Matches reports whether the tile fits a packed pattern as it is, without
+// rotating it: every constrained segment must be able to touch the border
+// the pattern requires
+func (t *Tile) Matches(pattern Pattern) bool {
+	if exactTouching {
+		return t.code.masked(pattern.Mask) == pattern.Value
+	}
+	for side := range Side(t.Sides()) {
+		for segment := range SegmentCount {
+			if border, ok := pattern.Requires(side, segment); ok && !CanTouch(t.code.segment(side, segment), border) {
+				return false
+			}
+		}
+	}
+	return true
 }
 
+// MatchesQuery reports whether the tile fits a query such as "C??F" as it
+// is; a malformed query matches no tile
 func (t *Tile) MatchesQuery(query string) bool {
-	return doesTileMatchQuery(t.Top(), string(query[0])) &&
-		doesTileMatchQuery(t.Right(), string(query[1])) &&
-		doesTileMatchQuery(t.Bottom(), string(query[2])) &&
-		doesTileMatchQuery(t.Left(), string(query[3]))
+	pattern, err := ParsePattern(query)
+	return err == nil && t.Matches(pattern)
+}
+
+func (t *Tile) Rotation() int {
+	return t.rotation
 }
 
-func CreateRandomTile() Tile {
+// Rotate returns a copy of the tile turned clockwise by the given number of
+// turns, a quarter turn each for a square tile. Negative values turn it
+// counter-clockwise.
+func (t *Tile) Rotate(turns int) Tile {
+	sides := t.Sides()
+	turns = ((turns % sides) + sides) % sides
+	// Turning clockwise moves every side one place further round
+	code := Code{layout: t.code.layout}
+	if code.layout != 0 {
+		code.turns = uint8((int(t.code.turns) + turns) % sides)
+	}
+	for side := range sides {
+		code.sides[(side+turns)%sides] = t.code.sides[side]
+	}
 	return Tile{
-		top:    getRandomBorder(),
-		left:   getRandomBorder(),
-		right:  getRandomBorder(),
-		bottom: getRandomBorder(),
+		code:     code,
+		rotation: (t.rotation + turns) % sides,
+		sides:    t.sides,
 	}
 }
 
-func CreateTile(borders string) Tile {
-	if len(borders) != 4 {
-		panic("Invalid borders string length")
+// Rotations returns the tile turned by every number of turns, starting with
+// the tile as it is.
+func (t *Tile) Rotations() []Tile {
+	rotations := make([]Tile, t.Sides())
+	for i := range rotations {
+		rotations[i] = t.Rotate(i)
 	}
-	return Tile{
-		top:    parseBorder(borders[0]),
-		right:  parseBorder(borders[1]),
-		bottom: parseBorder(borders[2]),
-		left:   parseBorder(borders[3]),
+	return rotations
+}
+
+// DistinctRotations returns the rotations of the tile that differ from each
+// other, so a symmetric tile such as RSRS yields only two.
+func (t *Tile) DistinctRotations() []Tile {
+	distinct := make([]Tile, 0, t.Sides())
+	for _, rotated := range t.Rotations() {
+		duplicate := false
+		for _, other := range distinct {
+			if other.code.sides == rotated.code.sides && other.sameFeatures(&rotated) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			distinct = append(distinct, rotated)
+		}
+	}
+	return distinct
+}
+
+// TypeCode returns the smallest code of any rotation of the tile, which is the
+// same for all tiles that are rotations of each other
+func (t *Tile) TypeCode() Code {
+	typeCode := t.code
+	for _, rotated := range t.Rotations() {
+		if rotated.code.compare(typeCode) < 0 {
+			typeCode = rotated.code
+		}
+	}
+	return typeCode
+}
+
+// MatchesQueryInAnyRotation reports whether the tile fits a query in some
+// rotation; a malformed query matches no tile
+func (t *Tile) MatchesQueryInAnyRotation(query string) bool {
+	pattern, err := ParsePattern(query)
+	return err == nil && t.MatchesInAnyRotation(pattern)
+}
+
+func (t *Tile) MatchesInAnyRotation(pattern Pattern) bool {
+	for _, rotated := range t.Rotations() {
+		if rotated.Matches(pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateRandomTile creates a tile with random borders drawn from rng, so the
+// same seed always produces the same tiles.
+func CreateRandomTile(rng *rand.Rand) Tile {
+	return CreateRandomTileWithSides(rng, int(SideCount))
+}
+
+// CreateRandomTileWithSides creates a square or hexagonal tile with random
+// borders drawn from rng
+func CreateRandomTileWithSides(rng *rand.Rand, sides int) Tile {
+	t := newTile(sides)
+	for side := range Side(sides) {
+		t.code = t.code.withSegments(side, UniformSegments(getRandomBorder(rng)))
+	}
+	return t
+}
+
+func newTile(sides int) Tile {
+	if sides == int(SideCount) {
+		return Tile{}
 	}
+	return Tile{sides: uint8(sides)}
 }
 
-func parseBorder(b byte) Border {
-	switch b {
-	case 'F':
-		return Field
-	case 'C':
-		return City
-	case 'S':
-		return Stream
-	case 'R':
-		return Road
-	default:
-		panic("Unknown border type")
+func CreateTile(borders string) Tile {
+	t, err := ParseTile(borders)
+	if err != nil {
+		panic(err)
 	}
+	return t
 }
 
-func getRandomBorder() Border {
-	return Border(rand.Intn(int(BorderLength)))
+// ParseTile reads a tile from its [Top][Right][Bottom][Left] border pattern,
+// or from the six borders of a hexagonal tile clockwise from the top right.
+// A side with segments is written as its three borders in parentheses, e.g.
+// (FRF)F(FRF)F for a road running through fields. The borders may be
+// followed by the features of the tile, see ParseLayout.
+func ParseTile(s string) (Tile, error) {
+	borders, features, _ := strings.Cut(s, " ")
+	t, err := parseBorders(borders)
+	if err != nil {
+		return Tile{}, err
+	}
+	if strings.TrimSpace(features) == "" {
+		return t, nil
+	}
+
+	layout, err := ParseLayout(&t, features)
+	if err != nil {
+		return Tile{}, fmt.Errorf("invalid tile %q: %w", s, err)
+	}
+	return t.WithLayout(layout)
+}
+
+func parseBorders(borders string) (Tile, error) {
+	sides, err := splitSides(borders)
+	if err != nil {
+		return Tile{}, fmt.Errorf("invalid tile %q: %w", borders, err)
+	}
+	t := newTile(len(sides))
+	for side, letters := range sides {
+		var segments Segments
+		for segment := range segments {
+			segments[segment], err = parseBorder(letters[segment%len(letters)])
+			if err != nil {
+				return Tile{}, fmt.Errorf("invalid tile %q: %w", borders, err)
+			}
+		}
+		t.code = t.code.withSegments(Side(side), segments)
+	}
+	return t, nil
 }
 
-func doesTileMatchQuery(tileBorder string, queryBorder string) bool {
-	return queryBorder == "?" || tileBorder == queryBorder
+func getRandomBorder(rng *rand.Rand) Border {
+	return Border(rng.Intn(BorderCount()))
 }
@@ -0,0 +1,489 @@
+package tile
+
+import (
+	"fmt"
+	"image/color"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestRotate(t *testing.T) {
+	original := CreateTile("CCFF")
+
+	expected := []string{"CCFF", "FCCF", "FFCC", "CFFC", "CCFF"}
+	for quarterTurns, pattern := range expected {
+		rotated := original.Rotate(quarterTurns)
+		if rotated.String() != pattern {
+			t.Errorf("Expected %d quarter turns to give %s, got %s", quarterTurns, pattern, rotated.String())
+		}
+		if rotated.Rotation() != quarterTurns%4 {
+			t.Errorf("Expected rotation %d, got %d", quarterTurns%4, rotated.Rotation())
+		}
+	}
+
+	counterClockwise := original.Rotate(-1)
+	if counterClockwise.String() != "CFFC" || counterClockwise.Rotation() != 3 {
+		t.Errorf("Expected -1 quarter turns to give CFFC with rotation 3, got %s with rotation %d",
+			counterClockwise.String(), counterClockwise.Rotation())
+	}
+}
+
+func TestMatchesQueryInAnyRotation(t *testing.T) {
+	tile := CreateTile("CCFF")
+
+	if tile.MatchesQuery("FCCF") {
+		t.Errorf("Expected unrotated tile not to match FCCF")
+	}
+	if !tile.MatchesQueryInAnyRotation("FCCF") {
+		t.Errorf("Expected a rotation of CCFF to match FCCF")
+	}
+	if tile.MatchesQueryInAnyRotation("CFCF") {
+		t.Errorf("Expected no rotation of CCFF to match CFCF")
+	}
+	for _, malformed := range []string{"CC", "CCXF", "(CC"} {
+		if tile.MatchesQuery(malformed) || tile.MatchesQueryInAnyRotation(malformed) {
+			t.Errorf("Expected the malformed query %q to match nothing", malformed)
+		}
+	}
+}
+
+func TestDistinctRotations(t *testing.T) {
+	tests := map[string]int{"CCFF": 4, "RSRS": 2, "FFFF": 1}
+	for borders, expected := range tests {
+		tile := CreateTile(borders)
+		if got := len(tile.DistinctRotations()); got != expected {
+			t.Errorf("Expected %s to have %d distinct rotations, got %d", borders, expected, got)
+		}
+	}
+}
+
+func TestTypeCodeIgnoresRotation(t *testing.T) {
+	first, second := CreateTile("CCFF"), CreateTile("FCCF")
+	if first.TypeCode() != second.TypeCode() {
+		t.Errorf("Expected CCFF and FCCF to share a type code")
+	}
+	other := CreateTile("CFCF")
+	if first.TypeCode() == other.TypeCode() {
+		t.Errorf("Expected CCFF and CFCF to have different type codes")
+	}
+}
+
+func TestTypeCodeIgnoresRotationOfLayouts(t *testing.T) {
+	first, second := CreateTile("CCFF TR"), CreateTile("FCCF RB")
+	if first.TypeCode() != second.TypeCode() {
+		t.Errorf("Expected CCFF TR and FCCF RB to share a type code")
+	}
+	if turned := first.Rotate(1); fmt.Sprint(sortedFeatures(turned.Features())) != fmt.Sprint(sortedFeatures(second.Features())) {
+		t.Errorf("Expected CCFF TR turned once to have the features of FCCF RB, got %v", turned.Features())
+	}
+
+	// The cities cannot be told apart by the borders alone
+	pennant, turned := CreateTile("CFCF T+ B"), CreateTile("CFCF T B+")
+	if pennant.TypeCode() != turned.TypeCode() {
+		t.Errorf("Expected CFCF T+ B and CFCF T B+ to share a type code")
+	}
+	if rotated := pennant.Rotate(2); fmt.Sprint(sortedFeatures(rotated.Features())) != fmt.Sprint(sortedFeatures(turned.Features())) {
+		t.Errorf("Expected CFCF T+ B turned twice to have the features of CFCF T B+, got %v", rotated.Features())
+	}
+}
+
+func TestHexTile(t *testing.T) {
+	tile, err := ParseTile("CFRFSF")
+	if err != nil {
+		t.Fatalf("Expected CFRFSF to parse, got error: %v", err)
+	}
+	if tile.Sides() != 6 {
+		t.Errorf("Expected a hex tile to have 6 sides, got %d", tile.Sides())
+	}
+
+	rotated := tile.Rotate(1)
+	if rotated.String() != "FCFRFS" || rotated.Sides() != 6 {
+		t.Errorf("Expected CFRFSF turned by one side to be FCFRFS, got %s", rotated.String())
+	}
+	if len(tile.Rotations()) != 6 {
+		t.Errorf("Expected 6 rotations of a hex tile, got %d", len(tile.Rotations()))
+	}
+	if !tile.MatchesQueryInAnyRotation("S?C???") {
+		t.Errorf("Expected CFRFSF to match S?C??? in some rotation")
+	}
+
+	pattern, _ := ParsePattern("??R?S?")
+	if pattern.Format(6) != "??R?S?" {
+		t.Errorf("Expected ??R?S?, got %s", pattern.Format(6))
+	}
+	if SideTop.OppositeIn(6) != 3 || Side(4).OppositeIn(6) != 1 {
+		t.Errorf("Expected opposite hex sides to be three sides apart")
+	}
+}
+
+func TestCustomBorderTypes(t *testing.T) {
+	types, err := ParseBorderTypes(strings.NewReader("# lakes\nF field #228b22\nS stream #1e90ff L\n\nL lake #4682b4 S\n"))
+	if err != nil {
+		t.Fatalf("Expected the border types to parse, got error: %v", err)
+	}
+	if len(types) != 3 || types[2].Name != "lake" || types[2].Color != (color.RGBA{70, 130, 180, 255}) || types[1].Touches != "L" {
+		t.Errorf("Unexpected border types %+v", types)
+	}
+	if types[0].Role != FieldRole || types[1].Role != StreamRole || types[2].Role != NoRole {
+		t.Errorf("Expected the roles to follow the names, got %+v", types)
+	}
+	if roles, err := ParseBorderTypes(strings.NewReader("W water #0000ff F socket role=stream\nF field #228b22 role=none\n")); err != nil || roles[0].Role != StreamRole || !roles[0].Socket || roles[1].Role != NoRole {
+		t.Errorf("Expected explicit roles, got %+v, %v", roles, err)
+	}
+	if _, err := ParseBorderTypes(strings.NewReader("W water #0000ff role=river\n")); err == nil {
+		t.Errorf("Expected an error for an unknown role")
+	}
+
+	if err := SetBorderTypes(types); err != nil {
+		t.Fatalf("Expected the border types to be registered, got error: %v", err)
+	}
+	t.Cleanup(func() { SetBorderTypes(DefaultBorderTypes) })
+
+	tile, err := ParseTile("FLSL")
+	if err != nil {
+		t.Fatalf("Expected FLSL to parse, got error: %v", err)
+	}
+	if tile.String() != "FLSL" || tile.Side(SideRight).Type().Name != "lake" {
+		t.Errorf("Expected a tile with lakes, got %s", tile.String())
+	}
+	if _, err := ParseTile("FCFC"); err == nil {
+		t.Errorf("Expected an error for a border that is no longer registered")
+	}
+}
+
+func TestCompatibleBorders(t *testing.T) {
+	types, err := ParseBorderTypes(strings.NewReader("R road #808080\nB bridge #d2b48c R\nM male #ffffff W socket\nW female #000000 socket\n"))
+	if err != nil {
+		t.Fatalf("Expected the border types to parse, got error: %v", err)
+	}
+	if err := SetBorderTypes(types); err != nil {
+		t.Fatalf("Expected the border types to be registered, got error: %v", err)
+	}
+	t.Cleanup(func() { SetBorderTypes(DefaultBorderTypes) })
+
+	road, bridge, male, female := Border(0), Border(1), Border(2), Border(3)
+	for _, test := range []struct {
+		a, b     Border
+		expected bool
+	}{
+		{road, road, true}, {road, bridge, true}, {bridge, road, true},
+		{male, female, true}, {female, male, true}, {male, male, false}, {female, female, false}, {road, male, false},
+	} {
+		if CanTouch(test.a, test.b) != test.expected {
+			t.Errorf("Expected CanTouch(%s, %s) to be %t", test.a, test.b, test.expected)
+		}
+	}
+
+	tile := CreateTile("BRMW")
+	for query, expected := range map[string]bool{"R???": true, "B???": true, "??W?": true, "??M?": false, "???M": true, "RBWM": true} {
+		if tile.MatchesQuery(query) != expected {
+			t.Errorf("Expected BRMW matching %s to be %t", query, expected)
+		}
+	}
+}
+
+func TestInvalidBorderTypes(t *testing.T) {
+	for _, invalid := range []string{"F field", "FF field #228b22", "F field green", "F field #228b22 L extra"} {
+		if _, err := ParseBorderTypes(strings.NewReader(invalid)); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+
+	for _, invalid := range [][]BorderType{
+		nil,
+		{{Char: 'F', Name: "field"}, {Char: 'F', Name: "forest"}},
+		{{Char: '?', Name: "wildcard"}},
+		{{Char: 'S', Name: "stream", Touches: "L"}},
+	} {
+		if err := SetBorderTypes(invalid); err == nil {
+			t.Errorf("Expected an error for %+v", invalid)
+		}
+	}
+}
+
+func TestSegmentedSides(t *testing.T) {
+	tile, err := ParseTile("(FRF)F(FCC)F")
+	if err != nil {
+		t.Fatalf("Expected (FRF)F(FCC)F to parse, got error: %v", err)
+	}
+	if tile.String() != "(FRF)F(FCC)F" || tile.Side(SideTop) != Road {
+		t.Errorf("Expected a road in the middle of the top side, got %s", tile.String())
+	}
+	if segments := tile.Segments(SideBottom); segments != (Segments{Field, City, City}) {
+		t.Errorf("Expected the bottom segments in clockwise order, got %v", segments)
+	}
+
+	rotated := tile.Rotate(1)
+	if rotated.String() != "F(FRF)F(FCC)" {
+		t.Errorf("Expected the segments to turn with their sides, got %s", rotated.String())
+	}
+
+	// The tile across a side sees its segments the other way round
+	if !tile.Segments(SideBottom).CanTouch(Segments{City, City, Field}) {
+		t.Errorf("Expected FCC to touch CCF")
+	}
+	if tile.Segments(SideBottom).CanTouch(Segments{Field, City, City}) {
+		t.Errorf("Expected FCC not to touch FCC")
+	}
+
+	for query, expected := range map[string]bool{"(FRF)???": true, "R???": false, "(?R?)?(F??)?": true, "??(CCF)?": false} {
+		if tile.MatchesQuery(query) != expected {
+			t.Errorf("Expected (FRF)F(FCC)F matching %s to be %t", query, expected)
+		}
+	}
+	if pattern, _ := ParsePattern("(?R?)?C?"); pattern.String() != "(?R?)?C?" {
+		t.Errorf("Expected (?R?)?C?, got %s", pattern.String())
+	}
+}
+
+func TestTileFeatures(t *testing.T) {
+	tile := CreateTile("C(FRF)(FRF)F T RcBc RrBl RlBrL M")
+	features := tile.Features()
+	if len(features) != 4 || !tile.Monastery() {
+		t.Fatalf("Expected 4 features and a monastery, got %v", features)
+	}
+	var road Feature
+	for _, feature := range features {
+		if feature.Border == Road && len(feature.Slots) == 2 {
+			road = feature
+		}
+	}
+	if road.Border != Road || len(road.Slots) != 2 || road.Ends() {
+		t.Errorf("Expected a road from the right to the bottom, got %v", road)
+	}
+	if tile.Describe() != "C(FRF)(FRF)F T RlBrL RcBc RrBl M" {
+		t.Errorf("Unexpected description %s", tile.Describe())
+	}
+	if reparsed := CreateTile(tile.Describe()); reparsed != tile {
+		t.Errorf("Expected the description to parse back to the same tile")
+	}
+
+	// The features turn with the tile
+	rotated := tile.Rotate(1)
+	if rotated.Describe() != "FC(FRF)(FRF) TBlLr R BcLc BrLl M" {
+		t.Errorf("Unexpected features after turning: %s", rotated.Describe())
+	}
+
+	// Leftover sides keep the default layout, without joining roads
+	crossing := CreateTile("RRFR T")
+	if len(crossing.Features()) != 4 || !crossing.Features()[1].Ends() {
+		t.Errorf("Expected every road of RRFR T to end on the tile, got %v", crossing.Features())
+	}
+
+	pennant := CreateTile("CCFF TR+")
+	if features := pennant.Features(); !features[0].Pennant || features[1].Pennant || pennant.Describe() != "CCFF TR+ BL" {
+		t.Errorf("Expected a city with a pennant, got %s", pennant.Describe())
+	}
+	if plain := CreateTile("CCFF TR"); plain.TypeCode() == pennant.TypeCode() {
+		t.Errorf("Expected the pennant to make a different tile type")
+	}
+
+	for _, invalid := range []string{"CFCF TR", "CFCF T TB", "CFCF X", "(FRF)FFF T", "CFCF Tx", "CCFF +"} {
+		if _, err := ParseTile(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestDefaultFeatures(t *testing.T) {
+	for borders, expected := range map[string]int{
+		"CCFF":         2, // the city and the field
+		"CFCF":         3, // the city sides are joined and divide the field
+		"RCRC":         3, // the roads end at the city running through
+		"(FRF)C(FRF)C": 7, // the roads end at the city, which divides the fields
+		"RFRF":         3, // the road runs through between two fields
+		"(FRF)F(FRF)F": 3, // the road divides the field
+		"(FRF)(FRF)FF": 3, // a bend leaves a field inside it
+		"RRRF":         4, // three roads end at a crossing
+		"RSRS":         2, // a road and a stream cross
+	} {
+		tile := CreateTile(borders)
+		if features := tile.Features(); len(features) != expected {
+			t.Errorf("Expected %d features on %s, got %v", expected, borders, features)
+		}
+	}
+}
+
+func TestDefaultFieldsEndAtCities(t *testing.T) {
+	tile := CreateTile("CFCF")
+	var fields [][]Slot
+	for _, feature := range tile.Features() {
+		if feature.Border == Field {
+			fields = append(fields, feature.Slots)
+		}
+	}
+	if len(fields) != 2 || fields[0][0].Side != SideRight || fields[1][0].Side != SideLeft {
+		t.Errorf("Expected the city to divide the field of CFCF in two, got %v", tile.Features())
+	}
+
+	crossing := CreateTile("RCRC")
+	for _, feature := range crossing.Features() {
+		if feature.Border == Road && !feature.Ends() {
+			t.Errorf("Expected the roads of RCRC to end at the city, got %v", feature)
+		}
+	}
+}
+
+func TestDefaultFeaturesFollowRoles(t *testing.T) {
+	// Fields and roads are told apart by their role, not by their index
+	types := []BorderType{
+		{Char: 'L', Name: "lake"},
+		{Char: 'R', Name: "road", Role: RoadRole},
+		{Char: 'F', Name: "field", Role: FieldRole},
+	}
+	if err := SetBorderTypes(types); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetBorderTypes(DefaultBorderTypes) })
+
+	tile := CreateTile("(FRF)L(FRF)F")
+	var fields int
+	for _, feature := range tile.Features() {
+		if feature.Border.Role() == FieldRole {
+			fields++
+		}
+	}
+	if fields != 2 {
+		t.Errorf("Expected the road to divide the field in two, got %v", tile.Features())
+	}
+}
+
+func TestDistinctRotationsKeepDifferentFeatures(t *testing.T) {
+	joined, split := CreateTile("CFCF TB"), CreateTile("CCCC TR BL")
+	if len(joined.DistinctRotations()) != 2 {
+		t.Errorf("Expected 2 distinct rotations of CFCF TB, got %d", len(joined.DistinctRotations()))
+	}
+	if len(split.DistinctRotations()) != 2 {
+		t.Errorf("Expected the cities of CCCC TR BL to tell its rotations apart, got %d", len(split.DistinctRotations()))
+	}
+	plain := CreateTile("CFCF")
+	if split.TypeCode() == plain.TypeCode() || !joined.MatchesQuery("C?C?") {
+		t.Errorf("Expected a layout to make a different tile type with the same borders")
+	}
+}
+
+func TestParseTile(t *testing.T) {
+	tile, err := ParseTile("RCRC")
+	if err != nil {
+		t.Fatalf("Expected RCRC to parse, got error: %v", err)
+	}
+	if tile.String() != "RCRC" {
+		t.Errorf("Expected RCRC, got %s", tile.String())
+	}
+
+	for _, invalid := range []string{"", "FFF", "FFFFF", "FFFFFFF", "FFXF", "(FR)FFF", "(FRF", "(FRFF)FFF", "ffff"} {
+		if _, err := ParseTile(invalid); err == nil {
+			t.Errorf("Expected an error when parsing %q", invalid)
+		}
+	}
+}
+
+func TestCreateRandomTileIsDeterministic(t *testing.T) {
+	first := rand.New(rand.NewSource(1))
+	second := rand.New(rand.NewSource(1))
+
+	for range 10 {
+		a, b := CreateRandomTile(first), CreateRandomTile(second)
+		if a != b {
+			t.Fatalf("Expected the same tiles for the same seed, got %s and %s", a.String(), b.String())
+		}
+	}
+}
+
+func TestParsePattern(t *testing.T) {
+	for _, query := range []string{"????", "C??F", "RCRC", "?S??"} {
+		pattern, err := ParsePattern(query)
+		if err != nil {
+			t.Fatalf("Expected %s to parse, got error: %v", query, err)
+		}
+		if pattern.String() != query {
+			t.Errorf("Expected %s to round-trip, got %s", query, pattern.String())
+		}
+	}
+
+	for _, invalid := range []string{"???", "C?X?", "?????"} {
+		if _, err := ParsePattern(invalid); err == nil {
+			t.Errorf("Expected an error when parsing %q", invalid)
+		}
+	}
+}
+
+func TestMatchesPackedPattern(t *testing.T) {
+	tile := CreateTile("CRFS")
+
+	matching := []string{"????", "C???", "?R??", "??F?", "???S", "CRFS", "C?F?"}
+	for _, query := range matching {
+		if !tile.MatchesQuery(query) {
+			t.Errorf("Expected CRFS to match %s", query)
+		}
+	}
+
+	notMatching := []string{"F???", "?C??", "??R?", "???F", "CRFF", "SCRF"}
+	for _, query := range notMatching {
+		if tile.MatchesQuery(query) {
+			t.Errorf("Expected CRFS not to match %s", query)
+		}
+	}
+}
+
+// matchesQueryByStrings is the string based matching that packed patterns
+// replaced, kept to benchmark against
+func matchesQueryByStrings(t *Tile, query string) bool {
+	matches := func(tileBorder, queryBorder string) bool {
+		return queryBorder == "?" || tileBorder == queryBorder
+	}
+	return matches(t.Top(), string(query[0])) &&
+		matches(t.Right(), string(query[1])) &&
+		matches(t.Bottom(), string(query[2])) &&
+		matches(t.Left(), string(query[3]))
+}
+
+var benchmarkQueries = []string{"????", "C???", "?R?F", "CCFF", "S??S", "FFFF"}
+
+func BenchmarkMatchesQueryByStrings(b *testing.B) {
+	tile := CreateTile("CCFF")
+	for b.Loop() {
+		for _, query := range benchmarkQueries {
+			matchesQueryByStrings(&tile, query)
+		}
+	}
+}
+
+func BenchmarkMatchesPacked(b *testing.B) {
+	tile := CreateTile("CCFF")
+	patterns := make([]Pattern, len(benchmarkQueries))
+	for i, query := range benchmarkQueries {
+		patterns[i], _ = ParsePattern(query)
+	}
+
+	for b.Loop() {
+		for _, pattern := range patterns {
+			tile.Matches(pattern)
+		}
+	}
+}
+
+func TestSixteenBorderTypes(t *testing.T) {
+	types := append([]BorderType(nil), DefaultBorderTypes...)
+	for _, char := range "ABDEGHIJKLMN" {
+		types = append(types, BorderType{Char: byte(char), Name: string(char)})
+	}
+	if err := SetBorderTypes(types); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetBorderTypes(DefaultBorderTypes) })
+
+	// The last border type fits the segments of a hexagonal tile
+	hex := CreateTile("NM(NLN)FCA")
+	if hex.String() != "NM(NLN)FCA" || hex.Side(0) != Border(BorderCount()-1) {
+		t.Errorf("Expected the borders to survive packing, got %s", hex.String())
+	}
+	if rotated := hex.Rotate(1); rotated.String() != "ANM(NLN)FC" || !rotated.MatchesQuery("A?M???") {
+		t.Errorf("Expected ANM(NLN)FC, got %s", rotated.String())
+	}
+
+	if err := SetBorderTypes(append(types, BorderType{Char: 'O', Name: "O"})); err == nil {
+		t.Errorf("Expected an error for 17 border types")
+	}
+}
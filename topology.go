@@ -0,0 +1,87 @@
+package main
+
+import "github.com/vakrim/carcassonne-wave-collapse/tile"
+
+// sideOffsets holds the row and column offset of the neighbour on each side
+var sideOffsets = [tile.SideCount][2]int{{-1, 0}, {0, 1}, {1, 0}, {0, -1}}
+
+// hexSideOffsets holds the row and column offset of the neighbour on each
+// side of a pointy-top hexagon in axial coordinates, clockwise from the top
+// right
+var hexSideOffsets = [tile.HexSideCount][2]int{{-1, 1}, {0, 1}, {1, 0}, {1, -1}, {0, -1}, {-1, 0}}
+
+// Topology decides which cells of a board are next to each other
+type Topology interface {
+	// Neighbour returns the cell across the given side of a cell, or false
+	// if there is none, e.g. past the edge of the board
+	Neighbour(row, col int, side tile.Side) (int, int, bool)
+
+	// Sides returns the number of sides of every cell, which is also the
+	// number of sides the tiles must have
+	Sides() int
+}
+
+// planeTopology is the square grid of an unbounded board, where every cell
+// has four neighbours
+type planeTopology struct{}
+
+func (planeTopology) Neighbour(row, col int, side tile.Side) (int, int, bool) {
+	return row + sideOffsets[side][0], col + sideOffsets[side][1], true
+}
+
+func (planeTopology) Sides() int {
+	return int(tile.SideCount)
+}
+
+// rectTopology is the square grid of a bounded board. Its top and bottom
+// edges, or its left and right edges, may be joined; with both pairs joined
+// the board is a torus.
+type rectTopology struct {
+	bounds   Rect
+	wrapRows bool // the top edge is joined to the bottom one
+	wrapCols bool // the left edge is joined to the right one
+}
+
+func (t rectTopology) Neighbour(row, col int, side tile.Side) (int, int, bool) {
+	neighbourRow, neighbourCol := row+sideOffsets[side][0], col+sideOffsets[side][1]
+	if t.wrapRows {
+		neighbourRow = t.bounds.Row + mod(neighbourRow-t.bounds.Row, t.bounds.Height)
+	}
+	if t.wrapCols {
+		neighbourCol = t.bounds.Col + mod(neighbourCol-t.bounds.Col, t.bounds.Width)
+	}
+	if !t.bounds.Contains(neighbourRow, neighbourCol) {
+		return 0, 0, false
+	}
+	return neighbourRow, neighbourCol, true
+}
+
+func (t rectTopology) Sides() int {
+	return int(tile.SideCount)
+}
+
+// hexTopology is a grid of pointy-top hexagons in axial coordinates: the row
+// is the axial r coordinate and the column the axial q one. Each row is
+// shifted half a cell to the right of the one above it, so a bounded board
+// is a rhombus.
+type hexTopology struct {
+	bounds    Rect
+	unbounded bool
+}
+
+func (t hexTopology) Neighbour(row, col int, side tile.Side) (int, int, bool) {
+	neighbourRow, neighbourCol := row+hexSideOffsets[side][0], col+hexSideOffsets[side][1]
+	if !t.unbounded && !t.bounds.Contains(neighbourRow, neighbourCol) {
+		return 0, 0, false
+	}
+	return neighbourRow, neighbourCol, true
+}
+
+func (t hexTopology) Sides() int {
+	return int(tile.HexSideCount)
+}
+
+// mod returns the remainder of a divided by b, from 0 to b-1
+func mod(a, b int) int {
+	return (a%b + b) % b
+}
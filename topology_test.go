@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+func TestTopologyNeighbours(t *testing.T) {
+	bounds := Rect{Height: 3, Width: 4}
+	tests := []struct {
+		name     string
+		topology Topology
+		row, col int
+		side     tile.Side
+		expected [2]int
+		ok       bool
+	}{
+		{"plane", planeTopology{}, 0, 0, tile.SideTop, [2]int{-1, 0}, true},
+		{"plane", planeTopology{}, 0, 0, tile.SideLeft, [2]int{0, -1}, true},
+		{"rectangle", rectTopology{bounds: bounds}, 0, 0, tile.SideTop, [2]int{}, false},
+		{"rectangle", rectTopology{bounds: bounds}, 1, 1, tile.SideRight, [2]int{1, 2}, true},
+		{"torus", rectTopology{bounds, true, true}, 0, 0, tile.SideTop, [2]int{2, 0}, true},
+		{"torus", rectTopology{bounds, true, true}, 2, 3, tile.SideRight, [2]int{2, 0}, true},
+		{"cylinder", rectTopology{bounds, false, true}, 2, 3, tile.SideBottom, [2]int{}, false},
+		{"hex", hexTopology{bounds: bounds}, 1, 1, 0, [2]int{0, 2}, true},
+		{"hex", hexTopology{bounds: bounds}, 1, 1, 3, [2]int{2, 0}, true},
+		{"hex", hexTopology{bounds: bounds}, 0, 3, 1, [2]int{}, false},
+		{"unbounded hex", hexTopology{unbounded: true}, 0, 0, 5, [2]int{-1, 0}, true},
+	}
+
+	for _, test := range tests {
+		row, col, ok := test.topology.Neighbour(test.row, test.col, test.side)
+		if ok != test.ok || (ok && [2]int{row, col} != test.expected) {
+			t.Errorf("%s: expected the neighbour of [%d][%d] on side %d to be %v (%t), got [%d][%d] (%t)",
+				test.name, test.row, test.col, test.side, test.expected, test.ok, row, col, ok)
+		}
+	}
+}
+
+func TestSolverMatchesSeamsOnTorus(t *testing.T) {
+	board := NewTorusBoard(3, 3)
+	start := tile.CreateTile("RFRF")
+	board.Set(1, 1, &start)
+
+	pile := Pile{tile.CreateTile("FRFR"), tile.CreateTile("FRFR")}
+	for range 6 {
+		pile = append(pile, tile.CreateTile("FFFF"))
+	}
+
+	result := NewSolver(&board, &pile, SolverOptions{Propagate: true}).Solve()
+	if !result.Solved {
+		t.Fatalf("Expected the torus to be filled, got error: %v", result.Err)
+	}
+
+	// Every side, including the ones on the edge, must match the tile on the
+	// other side of the board
+	for pos, placed := range board.tiles {
+		if !placed.Matches(board.PatternAt(pos.row, pos.col)) {
+			t.Errorf("Tile %s at [%d][%d] does not match %s", placed.String(), pos.row, pos.col, board.GetTilePattern(pos.row, pos.col))
+		}
+	}
+	if board.String() != "[FFFF][RFRF][FFFF]\n[FFFF][RFRF][FFFF]\n[FFFF][RFRF][FFFF]" {
+		t.Errorf("Expected the road to run round the torus, got:\n%s", board.String())
+	}
+}
+
+func TestSolverFillsHexBoard(t *testing.T) {
+	board := NewHexBoard(3, 3)
+	start := tile.CreateTile("RFFRFF")
+	board.Set(1, 1, &start)
+
+	pile := Pile{tile.CreateTile("FFRFFR"), tile.CreateTile("RFFRFF")}
+	for range 6 {
+		pile = append(pile, tile.CreateTile("FFFFFF"))
+	}
+
+	result := NewSolver(&board, &pile, SolverOptions{Propagate: true}).Solve()
+	if !result.Solved {
+		t.Fatalf("Expected the hex board to be filled, got error: %v", result.Err)
+	}
+	for pos, placed := range board.tiles {
+		if !placed.Matches(board.PatternAt(pos.row, pos.col)) {
+			t.Errorf("Tile %s at [%d][%d] does not match %s", placed.String(), pos.row, pos.col, board.GetTilePattern(pos.row, pos.col))
+		}
+	}
+}
+
+func TestParseHexBoard(t *testing.T) {
+	drawing := "[FRFFRF][      ]\n[######][FFFFFF]"
+	board, err := ParseBoard(drawing, false)
+	if err != nil {
+		t.Fatalf("Expected the hex drawing to parse, got error: %v", err)
+	}
+	if board.Sides() != 6 || !board.Blocked(1, 0) {
+		t.Errorf("Expected a hex board with a blocked cell, got %d sides", board.Sides())
+	}
+	if board.GetTilePattern(0, 1) != "??F?R?" {
+		t.Errorf("Expected ??F?R? next to the road, got %s", board.GetTilePattern(0, 1))
+	}
+	if board.String() != drawing {
+		t.Errorf("Expected the drawing back, got:\n%s", board.String())
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/vakrim/carcassonne-wave-collapse/scoring"
+)
+
+// Standing is how a bot did over a tournament
+type Standing struct {
+	Name string
+
+	// Wins counts the games the bot had the best score in, a tie counting
+	// as a share of a win
+	Wins   float64
+	Points int
+}
+
+// TournamentResult holds the standings of the bots in the order they entered
+type TournamentResult struct {
+	Games     int
+	Standings []Standing
+}
+
+// WinRate returns the part of the games a bot won
+func (r TournamentResult) WinRate(bot int) float64 {
+	if r.Games == 0 {
+		return 0
+	}
+	return r.Standings[bot].Wins / float64(r.Games)
+}
+
+// Write prints the standings, best first
+func (r TournamentResult) Write(out io.Writer) {
+	order := make([]int, len(r.Standings))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		switch {
+		case r.Standings[a].Wins > r.Standings[b].Wins:
+			return -1
+		case r.Standings[a].Wins < r.Standings[b].Wins:
+			return 1
+		}
+		return 0
+	})
+
+	fmt.Fprintf(out, "%d games\n", r.Games)
+	for _, i := range order {
+		standing := r.Standings[i]
+		fmt.Fprintf(out, "%-10s %5.1f wins  %5.1f%%  %6.1f points per game\n",
+			standing.Name, standing.Wins, 100*r.WinRate(i), float64(standing.Points)/float64(max(r.Games, 1)))
+	}
+}
+
+// RunTournament plays games between the bots without a display. setup
+// returns the board and pile of every game. The seats move round by one
+// every game so that no bot always plays first.
+func RunTournament(names []string, bots []Bot, games int, setup func() (*Board, *Pile, error), rules scoring.Rules) (TournamentResult, error) {
+	if len(bots) == 0 || len(names) != len(bots) {
+		return TournamentResult{}, errors.New("a tournament needs a name for every bot")
+	}
+
+	result := TournamentResult{Standings: make([]Standing, len(bots))}
+	for i, name := range names {
+		result.Standings[i].Name = name
+	}
+
+	for round := range games {
+		board, pile, err := setup()
+		if err != nil {
+			return result, err
+		}
+
+		// seats[i] is the bot in seat i
+		seats := make([]int, len(bots))
+		seatNames := make([]string, len(bots))
+		for i := range seats {
+			seats[i] = (i + round) % len(bots)
+			seatNames[i] = names[seats[i]]
+		}
+		game, err := NewGame(board, pile, seatNames, rules)
+		if err != nil {
+			return result, err
+		}
+		for !game.Over() {
+			if err := game.Play(bots[seats[game.Current()]].Choose(game)); err != nil {
+				return result, fmt.Errorf("%s: %w", names[seats[game.Current()]], err)
+			}
+		}
+
+		scores := game.projectedScores()
+		for seat, bot := range seats {
+			result.Standings[bot].Wins += share(scores, seat)
+			result.Standings[bot].Points += scores[seat]
+		}
+		result.Games++
+	}
+	return result, nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+// infoWidth is the width of the panel right of the board in play mode
+const infoWidth = 260
+
+// playerColors tell the meeples of the players apart
+var playerColors = []color.RGBA{
+	{220, 20, 60, 255},  // Crimson
+	{65, 105, 225, 255}, // Royal blue
+	{255, 215, 0, 255},  // Gold
+	{148, 0, 211, 255},  // Dark violet
+	{0, 0, 0, 255},      // Black
+	{255, 140, 0, 255},  // Dark orange
+}
+
+// HotSeatGame lets players take turns at the same screen. The drawn tile is
+// shown on the cell under the mouse in one of the orientations that fit
+// there; R or the right button picks the next one, a number key or M picks
+// the feature or monastery to put a meeple on and a click places the tile.
+type HotSeatGame struct {
+	game *Game
+	view *VisualizationGame
+
+	// hovered is the cell under the mouse and placements the orientations
+	// of the drawn tile that fit there, choice being the one shown
+	hovered    cellPosition
+	placements []Move
+	choice     int
+	meeple     int
+	message    string
+}
+
+func NewHotSeatGame(game *Game) *HotSeatGame {
+	g := &HotSeatGame{game: game, view: &VisualizationGame{pile: game.pile}, meeple: NoMeeple}
+	g.view.Show(game.Board(), nil)
+	return g
+}
+
+// runHotSeat opens a window to play the game in and prints the scores once
+// it is closed
+func runHotSeat(game *Game, out io.Writer) error {
+	ebiten.SetWindowSize(screenWidth+infoWidth, screenHeight)
+	ebiten.SetWindowTitle("Carcassonne Wave Collapse")
+	if err := ebiten.RunGame(NewHotSeatGame(game)); err != nil {
+		return err
+	}
+
+	for _, player := range game.Players() {
+		fmt.Fprintf(out, "%s: %d points\n", player.Name, player.Score)
+	}
+	return nil
+}
+
+func (g *HotSeatGame) Update() error {
+	if g.game.Over() {
+		return nil
+	}
+
+	g.hover()
+	if inpututil.IsKeyJustPressed(ebiten.KeyR) || inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
+		g.choice++
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyM) {
+		g.meeple = MonasteryMeeple
+	}
+	for key := ebiten.Key0; key <= ebiten.Key9; key++ {
+		if inpututil.IsKeyJustPressed(key) {
+			g.meeple = int(key-ebiten.Key0) - 1 // 0 puts no meeple down
+		}
+	}
+
+	if !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) || len(g.placements) == 0 {
+		return nil
+	}
+	move := g.placements[g.choice%len(g.placements)]
+	move.Meeple = g.meeple
+	if err := g.game.Play(move); err != nil {
+		g.message = "A meeple cannot go there"
+		return nil
+	}
+	g.message = ""
+	g.meeple = NoMeeple
+	g.placements = nil
+	g.view.Show(g.game.Board(), nil)
+	return nil
+}
+
+// hover finds the cell under the mouse and the orientations of the drawn
+// tile that fit it
+func (g *HotSeatGame) hover() {
+	x, y := ebiten.CursorPosition()
+	pos, ok := g.cellAt(x, y)
+	if ok && pos == g.hovered && g.placements != nil {
+		return
+	}
+
+	g.hovered, g.placements, g.choice = pos, []Move{}, 0
+	if !ok {
+		return
+	}
+	for _, move := range g.game.placements() {
+		if move.Row == pos.row && move.Col == pos.col {
+			g.placements = append(g.placements, move)
+		}
+	}
+}
+
+// cellAt returns the cell of the board at a screen position
+func (g *HotSeatGame) cellAt(x, y int) (cellPosition, bool) {
+	area := g.view.area
+	if !g.view.hex() {
+		if x < boardOffsetX || y < boardOffsetY {
+			return cellPosition{}, false
+		}
+		row, col := (y-boardOffsetY)/tileSize, (x-boardOffsetX)/tileSize
+		return cellPosition{area.Row + row, area.Col + col}, row < area.Height && col < area.Width
+	}
+
+	// The nearest hex centre, if the position is inside its hexagon
+	for row := range area.Height {
+		for col := range area.Width {
+			centerX, centerY := hexCenter(boardOffsetX, boardOffsetY, row, col)
+			if math.Hypot(float64(x)-float64(centerX), float64(y)-float64(centerY)) < hexSize*math.Sqrt(3)/2 {
+				return cellPosition{area.Row + row, area.Col + col}, true
+			}
+		}
+	}
+	return cellPosition{}, false
+}
+
+func (g *HotSeatGame) Draw(screen *ebiten.Image) {
+	g.view.mu.Lock()
+	defer g.view.mu.Unlock()
+
+	screen.Fill(color.RGBA{240, 240, 240, 255})
+	g.view.drawBoard(screen)
+	if len(g.placements) > 0 {
+		move := g.placements[g.choice%len(g.placements)]
+		g.drawTileAt(screen, &move.Tile, move.Row, move.Col)
+	}
+	for _, meeple := range g.game.Meeples() {
+		g.drawMeeple(screen, meeple)
+	}
+	g.drawInfo(screen)
+}
+
+func (g *HotSeatGame) Layout(outsideWidth, outsideHeight int) (int, int) {
+	width, height := g.view.Layout(outsideWidth, outsideHeight)
+	return width + infoWidth, height
+}
+
+// cellCenter returns the screen position of the centre of a cell
+func (g *HotSeatGame) cellCenter(row, col int) (float32, float32) {
+	row, col = row-g.view.area.Row, col-g.view.area.Col
+	if g.view.hex() {
+		return hexCenter(boardOffsetX, boardOffsetY, row, col)
+	}
+	return float32(boardOffsetX + col*tileSize + tileSize/2), float32(boardOffsetY + row*tileSize + tileSize/2)
+}
+
+func (g *HotSeatGame) drawTileAt(screen *ebiten.Image, t *tile.Tile, row, col int) {
+	x, y := g.cellCenter(row, col)
+	if g.view.hex() {
+		g.view.drawHexTile(screen, t, x, y)
+		return
+	}
+	g.view.drawTile(screen, t, int(x)-tileSize/2, int(y)-tileSize/2)
+}
+
+// drawMeeple draws a meeple between the middle of its tile and the first
+// slot of its feature, or in the middle for a monastery
+func (g *HotSeatGame) drawMeeple(screen *ebiten.Image, meeple Meeple) {
+	x, y := g.cellCenter(meeple.Row, meeple.Col)
+	if !meeple.Monastery {
+		sides := g.game.Board().Sides()
+		// Angle of the middle of the slot, clockwise from straight up
+		angle := 2 * math.Pi * (float64(meeple.Slot.Side) + (float64(meeple.Slot.Segment)+0.5)/3) / float64(sides)
+		if sides == int(tile.SideCount) {
+			angle -= math.Pi / 4 // the top side starts at the top left corner
+		}
+		x += float32(0.3 * tileSize * math.Sin(angle))
+		y -= float32(0.3 * tileSize * math.Cos(angle))
+	}
+	vector.DrawFilledCircle(screen, x, y, 5, playerColors[meeple.Player%len(playerColors)], true)
+	vector.StrokeCircle(screen, x, y, 5, 1, color.White, true)
+}
+
+func (g *HotSeatGame) drawInfo(screen *ebiten.Image) {
+	x := screen.Bounds().Dx() - infoWidth + 10
+	y := 10
+	line := func(format string, args ...any) {
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf(format, args...), x, y)
+		y += 16
+	}
+
+	for i, player := range g.game.Players() {
+		marker := "  "
+		if i == g.game.Current() && !g.game.Over() {
+			marker = "> "
+		}
+		line("%s%s: %d points, %d meeples", marker, player.Name, player.Score, player.Meeples)
+		vector.DrawFilledCircle(screen, float32(x-4), float32(y-8), 3, playerColors[i%len(playerColors)], true)
+	}
+	y += 16
+
+	if g.game.Over() {
+		line("Game over")
+		return
+	}
+	line("Tiles left: %d", g.game.pile.Size())
+	drawn := g.game.Drawn()
+	if len(g.placements) > 0 {
+		drawn = &g.placements[g.choice%len(g.placements)].Tile
+	}
+	line("Drawn: %s", drawn.Describe())
+	line("Meeple (0 for none):")
+	for i, feature := range drawn.Features() {
+		line("%s %d: %s %s", selected(g.meeple == i), i+1, feature.Border.Type().Name, slotSides(drawn, feature.Slots))
+	}
+	if drawn.Monastery() {
+		line("%s M: monastery", selected(g.meeple == MonasteryMeeple))
+	}
+	y += 16
+	line("Click to place, R to turn")
+	if g.message != "" {
+		line("%s", g.message)
+	}
+}
+
+func selected(on bool) string {
+	if on {
+		return "*"
+	}
+	return " "
+}
+
+// slotSides names the sides a feature reaches, e.g. TR
+func slotSides(t *tile.Tile, slots []tile.Slot) string {
+	var sb strings.Builder
+	for side := range tile.Side(t.Sides()) {
+		if !slices.ContainsFunc(slots, func(slot tile.Slot) bool { return slot.Side == side }) {
+			continue
+		}
+		if t.Sides() == int(tile.SideCount) {
+			sb.WriteByte("TRBL"[side])
+		} else {
+			sb.WriteByte('0' + byte(side))
+		}
+	}
+	return sb.String()
+}
@@ -7,26 +7,48 @@ import (
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
-// VisualizationSolver wraps the solving logic with visual updates
+// VisualizationSolver runs the headless Solver and mirrors its progress on screen
 type VisualizationSolver struct {
 	board   *Board
 	pile    *Pile
+	solver  *Solver
 	game    *VisualizationGame
 	delay   time.Duration
 	solving bool
 }
 
-func NewVisualizationSolver(board *Board, pile *Pile) *VisualizationSolver {
+func NewVisualizationSolver(board *Board, pile *Pile, options SolverOptions, delay time.Duration) *VisualizationSolver {
 	game := NewVisualizationGame(board, pile)
-	solver := &VisualizationSolver{
+	vs := &VisualizationSolver{
 		board:   board,
 		pile:    pile,
+		solver:  NewSolver(board, pile, options),
 		game:    game,
-		delay:   time.Millisecond * 500, // delay between steps
+		delay:   delay, // delay between steps
 		solving: false,
 	}
-	game.SetSolver(solver) // Set the solver reference for keyboard handling
-	return solver
+	vs.solver.Subscribe(vs.onSolverEvent)
+	game.SetSolver(vs) // Set the solver reference for keyboard handling
+	return vs
+}
+
+// runVisualization opens a window and solves the board in it, starting after
+// the given delay so the initial board can be seen. A tiled board is drawn
+// 2x2 so the seams of a wrapping board can be checked.
+func runVisualization(board *Board, pile *Pile, options SolverOptions, seed int64, startDelay, stepDelay time.Duration, tiled bool) error {
+	solver := NewVisualizationSolver(board, pile, options, stepDelay)
+	solver.game.SetTiled(tiled)
+
+	// Start solving in background after a brief delay
+	go func() {
+		time.Sleep(startDelay) // Wait before starting
+		solver.StartSolving()
+	}()
+
+	ebiten.SetWindowSize(screenWidth, screenHeight)
+	ebiten.SetWindowTitle(fmt.Sprintf("Carcassonne Wave Collapse Visualization (seed %d)", seed))
+
+	return ebiten.RunGame(solver)
 }
 
 func (vs *VisualizationSolver) StartSolving() {
@@ -40,12 +62,20 @@ func (vs *VisualizationSolver) StartSolving() {
 		}()
 
 		fmt.Println("Starting visualization solve...")
-		err := vs.solveWaveCollapseVisualized(0)
-		if err == nil {
+		vs.game.Show(vs.board, vs.solver)
+
+		// The solver changes the board while holding the lock of the view,
+		// which it only lets go of between its steps, see onSolverEvent
+		vs.game.mu.Lock()
+		result := vs.solver.Solve()
+		vs.game.mu.Unlock()
+		if result.Solved {
 			fmt.Println("Success! All tiles have been placed.")
 		} else {
-			fmt.Printf("Could not place all tiles: %v\n", err)
+			fmt.Printf("Could not place all tiles: %v\n", result.Err)
 		}
+		fmt.Printf("Placements: %d, backtracks: %d, time: %v\n",
+			result.Stats.Placements, result.Stats.Backtracks, result.Stats.Duration)
 	}()
 }
 
@@ -61,55 +91,21 @@ func (vs *VisualizationSolver) Layout(outsideWidth, outsideHeight int) (int, int
 	return vs.game.Layout(outsideWidth, outsideHeight)
 }
 
-func (vs *VisualizationSolver) solveWaveCollapseVisualized(recursiveCount int) error {
-	// Update possibilities display
-	vs.game.UpdatePossibilities()
-
-	// Check if all tiles are used
-	if len(*vs.pile) == 0 {
-		return nil // Success - all tiles used
-	}
-
-	sortedPositions := getSortedAvailablePositions(vs.board, vs.pile)
-
-	if len(sortedPositions) == 0 {
-		return fmt.Errorf("no more valid positions to place remaining %d tiles", len(*vs.pile))
+func (vs *VisualizationSolver) onSolverEvent(event SolverEvent) {
+	// Let the board be drawn as it is now while the solver waits. The view
+	// reads the counts the solver keeps up to date when it draws, so a step
+	// costs nothing for the cells it does not change.
+	vs.game.mu.Unlock()
+	defer vs.game.mu.Lock()
+
+	switch event.Type {
+	case TilePlaced:
+		// Wait to show the placement
+		vs.sleep()
+	case TileRemoved:
+		// Wait to show the backtrack
+		vs.sleepFaster()
 	}
-
-	currentTile := vs.pile.PeekTop()
-
-	for _, pos := range sortedPositions {
-		pattern := vs.board.GetTilePattern(pos.row, pos.col)
-
-		// Check if current tile matches this position
-		if currentTile.MatchesQuery(pattern) {
-			// Place the tile
-			placedTile := vs.pile.PopTop()
-			vs.board.tiles[pos.row][pos.col] = placedTile
-
-			// Update possibilities display after placement
-			vs.game.UpdatePossibilities()
-
-			// Wait to show the placement
-			vs.sleep()
-
-			err := vs.solveWaveCollapseVisualized(recursiveCount + 1)
-			if err == nil {
-				return nil // solved!
-			}
-
-			vs.board.tiles[pos.row][pos.col] = nil
-			vs.pile.PushTop(placedTile)
-
-			// Update possibilities display after backtrack
-			vs.game.UpdatePossibilities()
-
-			// Wait to show the backtrack
-			vs.sleepFaster()
-		}
-	}
-
-	return fmt.Errorf("current tile %s cannot be placed in any available position", currentTile.String())
 }
 
 func (vs *VisualizationSolver) sleep() {
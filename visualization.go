@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"image/color"
+	"sync"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
@@ -19,31 +20,77 @@ const (
 )
 
 var (
-	// Colors for different border types
-	fieldColor  = color.RGBA{34, 139, 34, 255}   // Forest green
-	cityColor   = color.RGBA{139, 69, 19, 255}   // Brown
-	streamColor = color.RGBA{30, 144, 255, 255}  // Dodger blue
-	roadColor   = color.RGBA{128, 128, 128, 255} // Gray
-	emptyColor  = color.RGBA{245, 245, 245, 255} // White smoke
+	// Border colours come from the registered border types
+	emptyColor   = color.RGBA{245, 245, 245, 255} // White smoke
+	blockedColor = color.RGBA{64, 64, 64, 255}    // Dark gray
+	pinnedColor  = color.RGBA{255, 215, 0, 255}   // Gold
 )
 
 type VisualizationGame struct {
-	board         *Board
-	pile          *Pile
+	// mu guards the board that is drawn: whoever changes it in the
+	// background, such as the solver, holds the lock meanwhile
+	mu     sync.Mutex
+	board  *Board
+	counts possibilitySource // nil to draw no counts
+
+	// area and possibilities are read from the board when it is drawn
+	area          Rect
 	possibilities [][]PossibilitiesCount
+	pile          *Pile
 	solver        *VisualizationSolver
+	// tiled draws the board 2x2 to show the seams of a wrapping board
+	tiled bool
 }
 
 func NewVisualizationGame(board *Board, pile *Pile) *VisualizationGame {
-	return &VisualizationGame{
-		board:         board,
-		pile:          pile,
-		possibilities: board.CountPossibilities(pile),
+	g := &VisualizationGame{pile: pile}
+	g.Show(board, NewPossibilityTracker(board, pile))
+	return g
+}
+
+// possibilitySource counts the tiles that fit the cells of an area, such as a
+// Solver or a PossibilityTracker
+type possibilitySource interface {
+	PossibilitiesIn(area Rect) [][]PossibilitiesCount
+}
+
+// Show draws the board and the possibility counts of its area from now on,
+// without counts when possibilities is nil. Nothing is copied, so whatever
+// changes the board outside of Update must hold the lock while it does.
+func (g *VisualizationGame) Show(board *Board, possibilities possibilitySource) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.board = board
+	g.counts = possibilities
+	g.refresh()
+}
+
+// refresh reads the area of the board and its counts as they are now. Only
+// the cells that are drawn are counted, once per frame rather than after
+// every change.
+func (g *VisualizationGame) refresh() {
+	g.area = g.board.Area()
+	g.possibilities = nil
+	if g.counts != nil {
+		g.possibilities = g.counts.PossibilitiesIn(g.area)
 	}
 }
 
-func (g *VisualizationGame) UpdatePossibilities() {
-	g.possibilities = g.board.CountPossibilities(g.pile)
+func (g *VisualizationGame) SetTiled(tiled bool) {
+	g.tiled = tiled
+}
+
+// copies returns how many times the board is drawn in each direction. Hex
+// boards never wrap, so they are always drawn once.
+func (g *VisualizationGame) copies() int {
+	if g.tiled && !g.hex() {
+		return 2
+	}
+	return 1
+}
+
+func (g *VisualizationGame) hex() bool {
+	return g.board.Sides() == int(tile.HexSideCount)
 }
 
 func (g *VisualizationGame) SetSolver(solver *VisualizationSolver) {
@@ -57,10 +104,18 @@ func (g *VisualizationGame) Update() error {
 			g.solver.delay = 0
 		}
 	}
+
+	// T switches between one copy of the board and a 2x2 grid of copies
+	if inpututil.IsKeyJustPressed(ebiten.KeyT) {
+		g.tiled = !g.tiled
+	}
 	return nil
 }
 
 func (g *VisualizationGame) Draw(screen *ebiten.Image) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
 	screen.Fill(color.RGBA{240, 240, 240, 255}) // Light gray background
 
 	// Draw the board
@@ -71,19 +126,51 @@ func (g *VisualizationGame) Draw(screen *ebiten.Image) {
 }
 
 func (g *VisualizationGame) Layout(outsideWidth, outsideHeight int) (int, int) {
-	return screenWidth, screenHeight
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// Grow the screen for boards that do not fit the default window
+	if g.hex() {
+		width, height := hexAreaSize(g.area)
+		return max(screenWidth, 2*boardOffsetX+width), max(screenHeight, 2*boardOffsetY+height)
+	}
+	width := max(screenWidth, 2*boardOffsetX+g.copies()*g.area.Width*tileSize)
+	height := max(screenHeight, 2*boardOffsetY+g.copies()*g.area.Height*tileSize)
+	return width, height
 }
 
 func (g *VisualizationGame) drawBoard(screen *ebiten.Image) {
-	for row := range g.board.tiles {
-		for col := range g.board.tiles[row] {
-			x := boardOffsetX + col*tileSize
-			y := boardOffsetY + row*tileSize
+	g.refresh()
+	if g.hex() {
+		g.drawHexBoardAt(screen, boardOffsetX, boardOffsetY)
+		return
+	}
+	for copyRow := range g.copies() {
+		for copyCol := range g.copies() {
+			g.drawBoardAt(screen,
+				boardOffsetX+copyCol*g.area.Width*tileSize,
+				boardOffsetY+copyRow*g.area.Height*tileSize)
+		}
+	}
+}
+
+func (g *VisualizationGame) drawBoardAt(screen *ebiten.Image, offsetX, offsetY int) {
+	// An unbounded board is drawn from the top left of its area, wherever
+	// that is
+	for i := range g.area.Height {
+		for j := range g.area.Width {
+			x := offsetX + j*tileSize
+			y := offsetY + i*tileSize
 
-			if g.board.tiles[row][col] != nil {
-				g.drawTile(screen, g.board.tiles[row][col], x, y)
+			if t := g.board.Get(g.area.Row+i, g.area.Col+j); t != nil {
+				g.drawTile(screen, t, x, y)
+				if g.board.Pinned(g.area.Row+i, g.area.Col+j) {
+					g.drawPinMarker(screen, x, y)
+				}
+			} else if g.board.Blocked(g.area.Row+i, g.area.Col+j) {
+				ebitenutil.DrawRect(screen, float64(x), float64(y), tileSize, tileSize, blockedColor)
 			} else {
-				g.drawEmptyTile(screen, x, y, row, col)
+				g.drawEmptyTile(screen, x, y, i, j)
 			}
 		}
 	}
@@ -93,24 +180,29 @@ func (g *VisualizationGame) drawTile(screen *ebiten.Image, t *tile.Tile, x, y in
 	// Draw tile background
 	ebitenutil.DrawRect(screen, float64(x), float64(y), tileSize, tileSize, color.RGBA{255, 255, 255, 255})
 
-	// Draw borders
+	// Draw borders, a third of the side for every segment in clockwise order
 	borderSize := 8.0
+	third := float64(tileSize) / 3
+	for side := range tile.SideCount {
+		for segment, border := range t.Segments(side) {
+			offset := third * float64(segment)
+			var segmentX, segmentY, width, height float64
+			switch side {
+			case tile.SideTop:
+				segmentX, segmentY, width, height = offset, 0, third, borderSize
+			case tile.SideRight:
+				segmentX, segmentY, width, height = tileSize-borderSize, offset, borderSize, third
+			case tile.SideBottom:
+				segmentX, segmentY, width, height = tileSize-offset-third, tileSize-borderSize, third, borderSize
+			case tile.SideLeft:
+				segmentX, segmentY, width, height = 0, tileSize-offset-third, borderSize, third
+			}
+			ebitenutil.DrawRect(screen, float64(x)+segmentX, float64(y)+segmentY, width, height, getBorderColor(border))
+		}
+	}
 
-	// Top border
-	topColor := getBorderColor(t.Top())
-	ebitenutil.DrawRect(screen, float64(x), float64(y), tileSize, borderSize, topColor)
-
-	// Right border
-	rightColor := getBorderColor(t.Right())
-	ebitenutil.DrawRect(screen, float64(x+tileSize-int(borderSize)), float64(y), borderSize, tileSize, rightColor)
-
-	// Bottom border
-	bottomColor := getBorderColor(t.Bottom())
-	ebitenutil.DrawRect(screen, float64(x), float64(y+tileSize-int(borderSize)), tileSize, borderSize, bottomColor)
-
-	// Left border
-	leftColor := getBorderColor(t.Left())
-	ebitenutil.DrawRect(screen, float64(x), float64(y), borderSize, tileSize, leftColor)
+	// Mark the side that was on top before the tile was rotated
+	g.drawRotationMarker(screen, t, x, y)
 
 	// Draw tile border
 	ebitenutil.DrawRect(screen, float64(x), float64(y), tileSize, 1, color.Black)
@@ -119,6 +211,30 @@ func (g *VisualizationGame) drawTile(screen *ebiten.Image, t *tile.Tile, x, y in
 	ebitenutil.DrawRect(screen, float64(x), float64(y+tileSize-1), tileSize, 1, color.Black)
 }
 
+func (g *VisualizationGame) drawRotationMarker(screen *ebiten.Image, t *tile.Tile, x, y int) {
+	const markerSize = 4
+	center := float64(tileSize-markerSize) / 2
+
+	markerX, markerY := center, 1.0 // original top stays on top
+	switch t.Rotation() {
+	case 1:
+		markerX, markerY = tileSize-1-markerSize, center
+	case 2:
+		markerX, markerY = center, tileSize-1-markerSize
+	case 3:
+		markerX, markerY = 1, center
+	}
+	ebitenutil.DrawRect(screen, float64(x)+markerX, float64(y)+markerY, markerSize, markerSize, color.Black)
+}
+
+// drawPinMarker marks a tile the solver may not remove with a dot in the middle
+func (g *VisualizationGame) drawPinMarker(screen *ebiten.Image, x, y int) {
+	const markerSize = 8
+	center := float64(tileSize-markerSize) / 2
+	ebitenutil.DrawRect(screen, float64(x)+center, float64(y)+center, markerSize, markerSize, pinnedColor)
+}
+
+// drawEmptyTile draws an empty cell, row and col being relative to the area
 func (g *VisualizationGame) drawEmptyTile(screen *ebiten.Image, x, y, row, col int) {
 	ebitenutil.DrawRect(screen, float64(x), float64(y), tileSize, tileSize, emptyColor)
 
@@ -145,7 +261,7 @@ func (g *VisualizationGame) drawInfo(screen *ebiten.Image) {
 	// Draw pile count and other info
 	infoY := 10
 	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Tiles remaining: %d", len(*g.pile)), 10, infoY)
-	
+
 	// Show current delay
 	delayText := "Normal speed"
 	if g.solver != nil && g.solver.delay == 0 {
@@ -156,21 +272,10 @@ func (g *VisualizationGame) drawInfo(screen *ebiten.Image) {
 	ebitenutil.DebugPrintAt(screen, delayText, 10, infoY+20)
 
 	// Draw instructions
-	ebitenutil.DebugPrintAt(screen, "Press SPACE to speed up", 10, infoY+40)
+	ebitenutil.DebugPrintAt(screen, "Press SPACE to speed up, T to tile the board 2x2", 10, infoY+40)
 	ebitenutil.DebugPrintAt(screen, "Close window to exit", 10, infoY+60)
 }
 
-func getBorderColor(border string) color.Color {
-	switch border {
-	case "F":
-		return fieldColor
-	case "C":
-		return cityColor
-	case "S":
-		return streamColor
-	case "R":
-		return roadColor
-	default:
-		return color.Black
-	}
+func getBorderColor(border tile.Border) color.Color {
+	return border.Type().Color
 }
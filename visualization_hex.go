@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/vakrim/carcassonne-wave-collapse/tile"
+)
+
+// hexSize is the distance from the centre of a hex cell to its corners
+const hexSize = tileSize / 2
+
+// whiteSubImage is the source image of the filled triangles, created on first
+// use as images cannot be made before the game runs
+var whiteSubImage *ebiten.Image
+
+func filledTriangleSource() *ebiten.Image {
+	if whiteSubImage == nil {
+		whiteImage := ebiten.NewImage(3, 3)
+		whiteImage.Fill(color.White)
+		whiteSubImage = whiteImage.SubImage(image.Rect(1, 1, 2, 2)).(*ebiten.Image)
+	}
+	return whiteSubImage
+}
+
+// hexCenter returns the screen position of the centre of a cell of the area,
+// row and col being relative to the area. Each row is shifted half a cell to
+// the right of the one above it.
+func hexCenter(offsetX, offsetY, row, col int) (float32, float32) {
+	width := math.Sqrt(3) * hexSize
+	x := float64(offsetX) + width*(float64(col)+float64(row)/2) + width/2
+	y := float64(offsetY) + hexSize*(1.5*float64(row)+1)
+	return float32(x), float32(y)
+}
+
+// hexAreaSize returns the screen size of an area of hex cells
+func hexAreaSize(area Rect) (int, int) {
+	width := math.Sqrt(3) * hexSize * (float64(area.Width) + float64(max(area.Height-1, 0))/2)
+	height := hexSize * (1.5*float64(area.Height) + 0.5)
+	return int(math.Ceil(width)), int(math.Ceil(height))
+}
+
+// hexCorner returns the corner of a hex cell where the given side starts,
+// going clockwise from the top corner
+func hexCorner(x, y float32, side tile.Side) (float32, float32) {
+	angle := math.Pi/3*float64(side) - math.Pi/2
+	return x + float32(hexSize*math.Cos(angle)), y + float32(hexSize*math.Sin(angle))
+}
+
+func (g *VisualizationGame) drawHexBoardAt(screen *ebiten.Image, offsetX, offsetY int) {
+	for i := range g.area.Height {
+		for j := range g.area.Width {
+			x, y := hexCenter(offsetX, offsetY, i, j)
+
+			if t := g.board.Get(g.area.Row+i, g.area.Col+j); t != nil {
+				g.drawHexTile(screen, t, x, y)
+				if g.board.Pinned(g.area.Row+i, g.area.Col+j) {
+					g.drawPinMarker(screen, int(x)-tileSize/2, int(y)-tileSize/2)
+				}
+			} else if g.board.Blocked(g.area.Row+i, g.area.Col+j) {
+				g.fillHex(screen, x, y, blockedColor)
+			} else {
+				g.drawEmptyHex(screen, x, y, i, j)
+			}
+		}
+	}
+}
+
+// drawHexTile fills the triangle between the centre and every side with the
+// colours of its segments, a third of the side each
+func (g *VisualizationGame) drawHexTile(screen *ebiten.Image, t *tile.Tile, x, y float32) {
+	for side := range tile.HexSideCount {
+		x0, y0 := hexCorner(x, y, side)
+		x1, y1 := hexCorner(x, y, side+1)
+		for segment, border := range t.Segments(side) {
+			from, to := float32(segment)/3, float32(segment+1)/3
+			g.fillPolygon(screen, getBorderColor(border), x, y,
+				x0+(x1-x0)*from, y0+(y1-y0)*from, x0+(x1-x0)*to, y0+(y1-y0)*to)
+		}
+	}
+
+	// Mark the side that was on the top right before the tile was rotated
+	x0, y0 := hexCorner(x, y, tile.Side(t.Rotation()))
+	x1, y1 := hexCorner(x, y, tile.Side(t.Rotation())+1)
+	vector.DrawFilledCircle(screen, x+0.8*((x0+x1)/2-x), y+0.8*((y0+y1)/2-y), 2, color.Black, true)
+
+	g.strokeHex(screen, x, y, color.Black)
+}
+
+// drawEmptyHex draws an empty cell, row and col being relative to the area
+func (g *VisualizationGame) drawEmptyHex(screen *ebiten.Image, x, y float32, row, col int) {
+	g.fillHex(screen, x, y, emptyColor)
+	g.strokeHex(screen, x, y, color.RGBA{200, 200, 200, 255})
+
+	if g.possibilities != nil && row < len(g.possibilities) && col < len(g.possibilities[row]) {
+		possCount := g.possibilities[row][col]
+		if !possCount.alreadyPlaced && possCount.possibilities > 0 {
+			text := fmt.Sprintf("%d", possCount.possibilities)
+			ebitenutil.DebugPrintAt(screen, text, int(x)-4, int(y)-8)
+		}
+	}
+}
+
+func (g *VisualizationGame) fillHex(screen *ebiten.Image, x, y float32, clr color.Color) {
+	var corners []float32
+	for side := range tile.HexSideCount {
+		cornerX, cornerY := hexCorner(x, y, side)
+		corners = append(corners, cornerX, cornerY)
+	}
+	g.fillPolygon(screen, clr, corners...)
+}
+
+func (g *VisualizationGame) strokeHex(screen *ebiten.Image, x, y float32, clr color.Color) {
+	for side := range tile.HexSideCount {
+		x0, y0 := hexCorner(x, y, side)
+		x1, y1 := hexCorner(x, y, side+1)
+		vector.StrokeLine(screen, x0, y0, x1, y1, 1, clr, true)
+	}
+}
+
+// fillPolygon fills the polygon through the given x, y pairs
+func (g *VisualizationGame) fillPolygon(screen *ebiten.Image, clr color.Color, points ...float32) {
+	var path vector.Path
+	path.MoveTo(points[0], points[1])
+	for i := 2; i < len(points); i += 2 {
+		path.LineTo(points[i], points[i+1])
+	}
+	path.Close()
+
+	vertices, indices := path.AppendVerticesAndIndicesForFilling(nil, nil)
+	r, gr, b, a := clr.RGBA()
+	for i := range vertices {
+		vertices[i].SrcX, vertices[i].SrcY = 1, 1
+		vertices[i].ColorR = float32(r) / 0xffff
+		vertices[i].ColorG = float32(gr) / 0xffff
+		vertices[i].ColorB = float32(b) / 0xffff
+		vertices[i].ColorA = float32(a) / 0xffff
+	}
+	screen.DrawTriangles(vertices, indices, filledTriangleSource(), &ebiten.DrawTrianglesOptions{AntiAlias: true})
+}